@@ -6,4 +6,5 @@ require (
 	github.com/hectorchu/gonano v0.1.15
 	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
 )
@@ -0,0 +1,117 @@
+package tokenchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+// Allowance gets the amount of tokens that spender may transfer on behalf of owner.
+func (t *Token) Allowance(owner, spender string) (allowance *big.Int) {
+	allowance, ok := t.allowances[owner][spender]
+	if !ok {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(allowance)
+}
+
+func (t *Token) setAllowance(owner, spender string, allowance *big.Int) {
+	if t.allowances[owner] == nil {
+		t.allowances[owner] = make(map[string]*big.Int)
+	}
+	t.allowances[owner][spender] = allowance
+}
+
+// Approve allows spender to transfer up to an amount of tokens on behalf of the account.
+func (t *Token) Approve(a *wallet.Account, spender string, amount *big.Int) (hash rpc.BlockHash, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if err = checkPositive(amount); err != nil {
+		return
+	}
+	if amount.BitLen() > 128 {
+		return hash, errors.New("Amount out of range")
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	return t.c.send(a, []string{spender}, &approveMessage{
+		token:  height,
+		amount: amount,
+	})
+}
+
+func (m *approveMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if checkPositive(m.amount) != nil {
+		return
+	}
+	spender, valid, err := c.getDestination(ctx, info.Contents)
+	if !valid {
+		return
+	}
+	t.setAllowance(info.BlockAccount, spender, m.amount)
+	return
+}
+
+// TransferFrom transfers an amount of tokens from owner to another account
+// using the allowance approved for the spending account.
+func (t *Token) TransferFrom(a *wallet.Account, owner, account string, amount *big.Int) (hash rpc.BlockHash, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if err = t.checkTransferFrom(owner, a.Address(), amount); err != nil {
+		return
+	}
+	if err = t.checkRestrictions(owner, a.Address(), account); err != nil {
+		return
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	return t.c.send(a, []string{owner, account}, &transferFromMessage{
+		token:  height,
+		amount: amount,
+	})
+}
+
+func (t *Token) checkTransferFrom(owner, spender string, amount *big.Int) (err error) {
+	if err = t.checkBalance(owner, amount); err != nil {
+		return
+	}
+	if t.Allowance(owner, spender).Cmp(amount) < 0 {
+		err = errors.New("Insufficient allowance")
+	}
+	return
+}
+
+func (m *transferFromMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	destinations, valid, err := c.getDestinations(ctx, info.Contents, 2)
+	if !valid {
+		return
+	}
+	owner, destination := destinations[0], destinations[1]
+	if t.checkTransferFrom(owner, info.BlockAccount, m.amount) != nil {
+		return false, nil
+	}
+	if t.checkRestrictions(owner, info.BlockAccount, destination) != nil {
+		return false, nil
+	}
+	allowance := t.Allowance(owner, info.BlockAccount)
+	t.setAllowance(owner, info.BlockAccount, allowance.Sub(allowance, m.amount))
+	t.transfer(hash, owner, destination, m.amount, "")
+	return
+}
@@ -0,0 +1,41 @@
+package tokenchain_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowance(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token   = genesis(t, chain, getAccount(0))
+		owner   = getAccount(0).Address()
+		spender = getAccount(1).Address()
+		amount1 = big.NewInt(1000)
+		amount2 = big.NewInt(600)
+	)
+	_, err := token.Approve(getAccount(0), spender, new(big.Int).Lsh(big.NewInt(1), 128))
+	assert.NotNil(t, err)
+	_, err = token.Approve(getAccount(0), spender, amount1)
+	require.Nil(t, err)
+	assert.Equal(t, amount1, token.Allowance(owner, spender))
+	_, err = token.TransferFrom(getAccount(1), owner, spender, new(big.Int).Add(amount1, big.NewInt(1)))
+	assert.NotNil(t, err)
+	_, err = token.TransferFrom(getAccount(0), owner, spender, amount2)
+	assert.NotNil(t, err)
+	_, err = token.TransferFrom(getAccount(1), owner, spender, amount2)
+	require.Nil(t, err)
+	assert.Equal(t, new(big.Int).Sub(amount1, amount2), token.Allowance(owner, spender))
+	assert.Equal(t, new(big.Int).Sub(supply, amount2), token.Balance(owner))
+	assert.Equal(t, amount2, token.Balance(spender))
+	for _, chain2 := range []*tokenchain.Chain{loadChain(t, chain.Address()), restoreChain(t, chain)} {
+		assertEqualChain(t, chain, chain2)
+		token2, err := chain2.Token(token.Hash())
+		require.Nil(t, err)
+		assert.Equal(t, token.Allowance(owner, spender), token2.Allowance(owner, spender))
+	}
+}
@@ -0,0 +1,129 @@
+package tokenchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/hectorchu/gonano/wallet"
+)
+
+// AtomicSwap coordinates a swap of tokens between two chains using HTLCs
+// under a shared hashlock. The initiator, the sender of the left leg,
+// locks tokens on the left chain for the participant, who then locks tokens
+// on the right chain for the initiator. The initiator claims on the right
+// chain revealing the secret, with which the participant claims on the left
+// chain. Timeouts are heights on the chain of each leg. The right leg must
+// time out before the left leg so that the participant has time to claim
+// after the secret is revealed. This is checked when both legs are on the
+// same chain; across chains the heights are not comparable, so the parties
+// must agree timeouts that leave the participant enough blocks to claim.
+//
+// All progress is recorded on the chains, so Step can be called again after
+// a crash to resume. The initiator must retain the secret.
+type AtomicSwap struct {
+	Hashlock    []byte
+	Left, Right AtomicSwapLeg
+}
+
+// AtomicSwapLeg represents a leg of the atomic swap.
+type AtomicSwapLeg struct {
+	Sender  string
+	Token   *Token
+	Amount  *big.Int
+	Timeout uint32
+}
+
+func (s *AtomicSwap) check() (err error) {
+	if len(s.Hashlock) != sha256.Size {
+		return errors.New("Invalid hashlock length")
+	}
+	if s.Left.Timeout == 0 || s.Right.Timeout == 0 {
+		return errors.New("Timeout is required")
+	}
+	if s.Left.Token.c.Address() == s.Right.Token.c.Address() && s.Right.Timeout >= s.Left.Timeout {
+		return errors.New("Right timeout must precede left timeout")
+	}
+	return
+}
+
+// find finds the HTLC locking the leg for a recipient.
+func (sl *AtomicSwapLeg) find(recipient string, hashlock []byte) *HTLC {
+	for _, h := range sl.Token.c.htlcs {
+		if bytes.Equal(h.token.hash, sl.Token.hash) &&
+			h.sender == sl.Sender && h.recipient == recipient &&
+			h.amount.Cmp(sl.Amount) == 0 && bytes.Equal(h.hashlock, hashlock) &&
+			h.timeout == sl.Timeout {
+			return h
+		}
+	}
+	return nil
+}
+
+// Step advances the swap as far as it can for the party owning the account
+// and returns whether that party is done, either by claiming or refunding.
+// The initiator passes the secret, the participant passes nil.
+func (s *AtomicSwap) Step(a *wallet.Account, secret []byte) (done bool, err error) {
+	if err = s.check(); err != nil {
+		return
+	}
+	if err = s.Left.Token.c.Parse(); err != nil {
+		return
+	}
+	if err = s.Right.Token.c.Parse(); err != nil {
+		return
+	}
+	left := s.Left.find(s.Right.Sender, s.Hashlock)
+	right := s.Right.find(s.Left.Sender, s.Hashlock)
+	switch a.Address() {
+	case s.Left.Sender:
+		return s.stepInitiator(a, secret, left, right)
+	case s.Right.Sender:
+		return s.stepParticipant(a, left, right)
+	}
+	return false, errors.New("Account is not party to the swap")
+}
+
+func (s *AtomicSwap) stepInitiator(a *wallet.Account, secret []byte, left, right *HTLC) (done bool, err error) {
+	switch {
+	case right != nil && right.preimage != nil, left != nil && left.refunded:
+		return true, nil
+	case left == nil:
+		if hash := sha256.Sum256(secret); !bytes.Equal(hash[:], s.Hashlock) {
+			return false, errors.New("Secret does not match hashlock")
+		}
+		_, err = LockHTLC(s.Left.Token.c, a, s.Right.Sender, s.Left.Token, s.Left.Amount, s.Hashlock, s.Left.Timeout)
+	case right != nil && right.claimable(a.Address(), secret):
+		if _, err = right.Claim(a, secret); err == nil {
+			return true, nil
+		}
+	case left.refundable(a.Address()):
+		if _, err = left.Refund(a); err == nil {
+			return true, nil
+		}
+	}
+	return
+}
+
+func (s *AtomicSwap) stepParticipant(a *wallet.Account, left, right *HTLC) (done bool, err error) {
+	switch {
+	case left != nil && left.preimage != nil, right != nil && right.refunded:
+		return true, nil
+	case left == nil:
+	case right == nil:
+		if !left.Active() || left.expired {
+			return true, nil
+		}
+		_, err = LockHTLC(s.Right.Token.c, a, s.Left.Sender, s.Right.Token, s.Right.Amount, s.Hashlock, s.Right.Timeout)
+	case right.preimage != nil:
+		if _, err = left.Claim(a, right.preimage); err == nil {
+			return true, nil
+		}
+	case right.refundable(a.Address()):
+		if _, err = right.Refund(a); err == nil {
+			return true, nil
+		}
+	}
+	return
+}
@@ -0,0 +1,125 @@
+package tokenchain_test
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAtomicSwap(t *testing.T) {
+	var (
+		chain1   = newChain(t)
+		chain2   = newChain(t)
+		token1   = genesis(t, chain1, getAccount(0))
+		token2   = genesis(t, chain2, getAccount(1))
+		amount1  = big.NewInt(1000)
+		amount2  = big.NewInt(2000)
+		secret   = []byte("0123456789abcdef0123456789abcdef")
+		hashlock = sha256.Sum256(secret)
+	)
+	swap := &tokenchain.AtomicSwap{
+		Hashlock: hashlock[:],
+		Left: tokenchain.AtomicSwapLeg{
+			Sender:  getAccount(0).Address(),
+			Token:   token1,
+			Amount:  amount1,
+			Timeout: 1 << 30,
+		},
+		Right: tokenchain.AtomicSwapLeg{
+			Sender:  getAccount(1).Address(),
+			Token:   token2,
+			Amount:  amount2,
+			Timeout: 1 << 29,
+		},
+	}
+	done, err := swap.Step(getAccount(1), nil)
+	require.Nil(t, err)
+	assert.False(t, done)
+	_, err = swap.Step(getAccount(0), []byte("wrong"))
+	assert.NotNil(t, err)
+	done, err = swap.Step(getAccount(0), secret)
+	require.Nil(t, err)
+	assert.False(t, done)
+	assert.Equal(t, new(big.Int).Sub(supply, amount1), token1.Balance(getAccount(0).Address()))
+	chain1 = restoreChain(t, chain1)
+	token1, err = chain1.Token(token1.Hash())
+	require.Nil(t, err)
+	swap.Left.Token = token1
+	done, err = swap.Step(getAccount(1), nil)
+	require.Nil(t, err)
+	assert.False(t, done)
+	done, err = swap.Step(getAccount(1), nil)
+	require.Nil(t, err)
+	assert.False(t, done)
+	done, err = swap.Step(getAccount(0), secret)
+	require.Nil(t, err)
+	assert.True(t, done)
+	done, err = swap.Step(getAccount(1), nil)
+	require.Nil(t, err)
+	assert.True(t, done)
+	assert.Equal(t, new(big.Int).Sub(supply, amount1), token1.Balance(getAccount(0).Address()))
+	assert.Equal(t, amount1, token1.Balance(getAccount(1).Address()))
+	assert.Equal(t, new(big.Int).Sub(supply, amount2), token2.Balance(getAccount(1).Address()))
+	assert.Equal(t, amount2, token2.Balance(getAccount(0).Address()))
+	assertEqualChain(t, chain1, loadChain(t, chain1.Address()))
+	assertEqualChain(t, chain2, restoreChain(t, chain2))
+}
+
+func TestAtomicSwapRefund(t *testing.T) {
+	var (
+		chain    = newChain(t)
+		token1   = genesis(t, chain, getAccount(0))
+		token2   = genesis(t, chain, getAccount(1))
+		amount1  = big.NewInt(1000)
+		amount2  = big.NewInt(2000)
+		secret   = []byte("0123456789abcdef0123456789abcdef")
+		hashlock = sha256.Sum256(secret)
+	)
+	height, err := chain.BlockHeight(token2.Hash())
+	require.Nil(t, err)
+	swap := &tokenchain.AtomicSwap{
+		Hashlock: hashlock[:],
+		Left: tokenchain.AtomicSwapLeg{
+			Sender:  getAccount(0).Address(),
+			Token:   token1,
+			Amount:  amount1,
+			Timeout: height + 3,
+		},
+		Right: tokenchain.AtomicSwapLeg{
+			Sender:  getAccount(1).Address(),
+			Token:   token2,
+			Amount:  amount2,
+			Timeout: height + 3,
+		},
+	}
+	_, err = swap.Step(getAccount(0), secret)
+	assert.NotNil(t, err)
+	swap.Left.Timeout++
+	done, err := swap.Step(getAccount(0), secret)
+	require.Nil(t, err)
+	assert.False(t, done)
+	done, err = swap.Step(getAccount(1), nil)
+	require.Nil(t, err)
+	assert.False(t, done)
+	done, err = swap.Step(getAccount(0), secret)
+	require.Nil(t, err)
+	assert.False(t, done)
+	done, err = swap.Step(getAccount(1), nil)
+	require.Nil(t, err)
+	assert.True(t, done)
+	done, err = swap.Step(getAccount(0), secret)
+	require.Nil(t, err)
+	assert.True(t, done)
+	for _, h := range chain.HTLCs() {
+		assert.False(t, h.Active())
+		assert.True(t, h.Expired())
+	}
+	assert.Equal(t, supply, token1.Balance(getAccount(0).Address()))
+	assert.Equal(t, supply, token2.Balance(getAccount(1).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
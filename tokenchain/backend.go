@@ -0,0 +1,24 @@
+package tokenchain
+
+import (
+	"time"
+
+	"github.com/hectorchu/gonano/rpc"
+)
+
+// NodeBackend represents the Nano node that chain blocks are queried from
+// and published to. *rpc.Client implements it.
+type NodeBackend interface {
+	AccountInfo(account string) (info rpc.AccountInfo, err error)
+	AccountsPending(accounts []string, count int64) (pending map[string]rpc.HashToPendingMap, err error)
+	ActiveDifficulty() (multiplier float64, networkCurrent, networkMinimum, networkReceiveCurrent, networkReceiveMinimum rpc.HexData, difficultyTrend []float64, err error)
+	BlockInfo(hash rpc.BlockHash) (info rpc.BlockInfo, err error)
+	BlocksInfo(hashes []rpc.BlockHash) (blocks map[string]*rpc.BlockInfo, err error)
+	Blocks(hashes []rpc.BlockHash) (blocks map[string]*rpc.Block, err error)
+	Ledger(account string, count int64, modifiedSince time.Time) (accounts map[string]rpc.AccountInfo, err error)
+	Process(block *rpc.Block, subtype string) (hash rpc.BlockHash, err error)
+	Successors(block rpc.BlockHash, count int64) (blocks []rpc.BlockHash, err error)
+	WorkGenerate(hash rpc.BlockHash, difficulty rpc.HexData) (work, difficulty2 rpc.HexData, multiplier float64, err error)
+}
+
+var _ NodeBackend = (*rpc.Client)(nil)
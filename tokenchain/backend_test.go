@@ -0,0 +1,134 @@
+package tokenchain_test
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/hectorchu/nano-token-protocol/tokenchain/nanosim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cachingBackend caches block info, which is immutable once confirmed.
+type cachingBackend struct {
+	*rpc.Client
+	blocks       map[string]rpc.BlockInfo
+	hits, misses int
+}
+
+func (b *cachingBackend) BlockInfo(hash rpc.BlockHash) (info rpc.BlockInfo, err error) {
+	if info, ok := b.blocks[hash.String()]; ok {
+		b.hits++
+		return info, nil
+	}
+	b.misses++
+	if info, err = b.Client.BlockInfo(hash); err == nil {
+		b.blocks[hash.String()] = info
+	}
+	return
+}
+
+func TestNodeBackend(t *testing.T) {
+	chain := newChain(t)
+	genesis(t, chain, getAccount(0))
+	backend := &cachingBackend{
+		Client: &rpc.Client{URL: rpcURL},
+		blocks: make(map[string]rpc.BlockInfo),
+	}
+	for i := 0; i < 2; i++ {
+		chain2, err := tokenchain.LoadChainWithBackend(chain.Address(), backend)
+		require.Nil(t, err)
+		require.Nil(t, chain2.Parse())
+		assertEqualChain(t, chain, chain2)
+	}
+	assert.NotZero(t, backend.misses)
+	assert.GreaterOrEqual(t, backend.hits, backend.misses)
+}
+
+func TestSimBackend(t *testing.T) {
+	chain := newChain(t)
+	genesis(t, chain, getAccount(0))
+	chain2, err := tokenchain.LoadChainWithBackend(chain.Address(), sim.Ledger)
+	require.Nil(t, err)
+	require.Nil(t, chain2.Parse())
+	assertEqualChain(t, chain, chain2)
+}
+
+// publishingBackend counts published blocks by subtype.
+type publishingBackend struct {
+	tokenchain.NodeBackend
+	published map[string]int
+}
+
+func (b *publishingBackend) Process(block *rpc.Block, subtype string) (hash rpc.BlockHash, err error) {
+	b.published[subtype]++
+	return b.NodeBackend.Process(block, subtype)
+}
+
+func TestPublishBackend(t *testing.T) {
+	backend := &publishingBackend{
+		NodeBackend: sim.Ledger,
+		published:   make(map[string]int),
+	}
+	chain, err := tokenchain.NewChainFromSeedWithBackend(newSeed(t), backend)
+	require.Nil(t, err)
+	_, err = getAccount(0).Send(chain.Address(), big.NewInt(1))
+	require.Nil(t, err)
+	require.Nil(t, chain.WaitForOpen())
+	assert.Equal(t, map[string]int{"receive": 1}, backend.published)
+	token := genesis(t, chain, getAccount(0))
+	_, _, err = token.TransferMany(getAccount(0), []tokenchain.Payout{
+		{Account: getAccount(1).Address(), Amount: big.NewInt(1000)},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 3, backend.published["receive"])
+	assert.NotZero(t, backend.published["send"])
+	assert.NotZero(t, backend.published["change"])
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+}
+
+func newSeed(t *testing.T) (seed []byte) {
+	seed = make([]byte, 32)
+	_, err := rand.Read(seed)
+	require.Nil(t, err)
+	return
+}
+
+func TestLedgerBackend(t *testing.T) {
+	var (
+		ledger = nanosim.NewLedger()
+		seed   = newSeed(t)
+		amount = big.NewInt(1000)
+	)
+	// A chain's account is the first account of a wallet with its seed, so
+	// a chain opens the account the wallet sends from.
+	opener, err := tokenchain.NewChainFromSeedWithBackend(seed, ledger)
+	require.Nil(t, err)
+	_, err = ledger.Fund(opener.Address(), big.NewInt(1e12))
+	require.Nil(t, err)
+	require.Nil(t, opener.WaitForOpen())
+	w, err := wallet.NewWallet(seed)
+	require.Nil(t, err)
+	w.RPC.URL = "http://127.0.0.1:1"
+	a, err := w.NewAccount(nil)
+	require.Nil(t, err)
+	require.Equal(t, opener.Address(), a.Address())
+	chain, err := tokenchain.NewChainFromSeedWithBackend(newSeed(t), ledger)
+	require.Nil(t, err)
+	_, err = ledger.Fund(chain.Address(), big.NewInt(1))
+	require.Nil(t, err)
+	require.Nil(t, chain.WaitForOpen())
+	token, err := tokenchain.TokenGenesis(chain, a, "TOKEN", supply, 5)
+	require.Nil(t, err)
+	_, err = token.Transfer(a, getAccount(1).Address(), amount)
+	require.Nil(t, err)
+	assert.Equal(t, amount, token.Balance(getAccount(1).Address()))
+	chain2, err := tokenchain.LoadChainWithBackend(chain.Address(), ledger)
+	require.Nil(t, err)
+	require.Nil(t, chain2.Parse())
+	assertEqualChain(t, chain, chain2)
+}
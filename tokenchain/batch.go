@@ -0,0 +1,142 @@
+package tokenchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/util"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+// Payouts are encoded as the account's public key followed by the amount's
+// length and its minimal big-endian bytes, after a count of payouts. Up to
+// 101 payouts of amounts below 2^8 fit in one message, or 70 of the widest
+// amounts.
+const (
+	minPayoutLen = 32 + 1 + 1
+	maxPayouts   = (maxExtensionLen - 2) / minPayoutLen
+)
+
+// Payout represents a payment to an account in a batch transfer.
+type Payout struct {
+	Account string
+	Amount  *big.Int
+}
+
+// PayoutResult represents the outcome of a payout in a batch transfer.
+// Err is set if the payout was rejected, otherwise Balance holds the
+// account's balance after the transfer.
+type PayoutResult struct {
+	Payout
+	Balance *big.Int
+	Err     error
+}
+
+func (p Payout) check() (err error) {
+	if _, err = util.AddressToPubkey(p.Account); err != nil {
+		return
+	}
+	if p.Amount.Sign() <= 0 {
+		return errors.New("Amount is not positive")
+	}
+	if p.Amount.BitLen() > 128 {
+		err = errors.New("Amount out of range")
+	}
+	return
+}
+
+// payoutsLen returns the encoded length of a list of payouts.
+func payoutsLen(payouts []Payout) (n int) {
+	n = 2
+	for _, p := range payouts {
+		n += 32 + 1 + len(p.Amount.Bytes())
+	}
+	return
+}
+
+// TransferMany transfers amounts of tokens to many accounts in one message.
+// If any payout is invalid or the balance does not cover them all, nothing
+// is transferred. The payouts must fit in one message, which holds from 70
+// to 101 payouts depending on the size of the amounts.
+func (t *Token) TransferMany(a *wallet.Account, payouts []Payout) (hash rpc.BlockHash, results []PayoutResult, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if len(payouts) == 0 || len(payouts) > maxPayouts {
+		return nil, nil, errors.New("Number of payouts out of range")
+	}
+	results = make([]PayoutResult, len(payouts))
+	total := new(big.Int)
+	for i, p := range payouts {
+		results[i].Payout = p
+		if results[i].Err = p.check(); results[i].Err == nil {
+			results[i].Err = t.checkRestrictions(p.Account)
+		}
+		if results[i].Err != nil {
+			err = errors.New("Invalid payout")
+			continue
+		}
+		total.Add(total, p.Amount)
+	}
+	if err != nil {
+		return
+	}
+	if payoutsLen(payouts) > maxExtensionLen {
+		return nil, results, errors.New("Payouts do not fit in one message")
+	}
+	if err = t.checkBalance(a.Address(), total); err != nil {
+		return
+	}
+	if err = t.checkRestrictions(a.Address()); err != nil {
+		return
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	hash, err = t.c.send(a, nil, &transferManyMessage{
+		token:   height,
+		amount:  total,
+		payouts: payouts,
+	})
+	if err != nil {
+		return
+	}
+	for i, p := range payouts {
+		results[i].Balance = t.Balance(p.Account)
+	}
+	return
+}
+
+func (m *transferManyMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if t.checkBalance(info.BlockAccount, m.amount) != nil || t.checkRestrictions(info.BlockAccount) != nil {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 0)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil {
+		return false, nil
+	}
+	total := new(big.Int)
+	for _, p := range m.payouts {
+		if p.check() != nil || t.checkRestrictions(p.Account) != nil {
+			return false, nil
+		}
+		total.Add(total, p.Amount)
+	}
+	if total.Cmp(m.amount) != 0 {
+		return false, nil
+	}
+	for _, p := range m.payouts {
+		t.transfer(hash, info.BlockAccount, p.Account, p.Amount, "")
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package tokenchain_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/gonano/wallet"
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func payee(t *testing.T, i int) string {
+	seed := make([]byte, 32)
+	seed[0], seed[1] = 0xff, byte(i)
+	w, err := wallet.NewWallet(seed)
+	require.Nil(t, err)
+	a, err := w.NewAccount(nil)
+	require.Nil(t, err)
+	return a.Address()
+}
+
+func TestTransferMany(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token   = genesis(t, chain, getAccount(0))
+		payouts []tokenchain.Payout
+		total   = new(big.Int)
+	)
+	for i := 0; i < 40; i++ {
+		amount := big.NewInt(int64(1000 + i))
+		payouts = append(payouts, tokenchain.Payout{Account: payee(t, i), Amount: amount})
+		total.Add(total, amount)
+	}
+	_, results, err := token.TransferMany(getAccount(0), []tokenchain.Payout{
+		{Account: payee(t, 0), Amount: big.NewInt(1)},
+		{Account: "nano_invalid", Amount: big.NewInt(1)},
+	})
+	assert.NotNil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.NotNil(t, results[1].Err)
+	hash, results, err := token.TransferMany(getAccount(0), []tokenchain.Payout{
+		{Account: payee(t, 0), Amount: big.NewInt(1)},
+		{Account: payee(t, 1), Amount: new(big.Int)},
+	})
+	assert.NotNil(t, err)
+	assert.Nil(t, hash)
+	assert.Nil(t, results[0].Err)
+	assert.NotNil(t, results[1].Err)
+	assert.Equal(t, supply, token.Balance(getAccount(0).Address()))
+	_, _, err = token.TransferMany(getAccount(1), payouts)
+	assert.NotNil(t, err)
+	_, results, err = token.TransferMany(getAccount(0), payouts)
+	require.Nil(t, err)
+	require.Len(t, results, len(payouts))
+	for i, p := range payouts {
+		assert.Nil(t, results[i].Err)
+		assert.Equal(t, p.Amount, results[i].Balance)
+		assert.Equal(t, p.Amount, token.Balance(p.Account))
+	}
+	assert.Equal(t, new(big.Int).Sub(supply, total), token.Balance(getAccount(0).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
+
+func TestTransferManyCapacity(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token   = genesis(t, chain, getAccount(0))
+		payouts []tokenchain.Payout
+		wide    = new(big.Int).Lsh(big.NewInt(1), 120)
+	)
+	for i := 0; i < 102; i++ {
+		payouts = append(payouts, tokenchain.Payout{Account: payee(t, i), Amount: big.NewInt(int64(1 + i))})
+	}
+	_, _, err := token.TransferMany(getAccount(0), payouts)
+	assert.NotNil(t, err)
+	_, _, err = token.TransferMany(getAccount(0), payouts[:101])
+	require.Nil(t, err)
+	for _, p := range payouts[:101] {
+		assert.Equal(t, p.Amount, token.Balance(p.Account))
+	}
+	token, err = tokenchain.TokenGenesis(chain, getAccount(0), "WIDE", new(big.Int).Lsh(wide, 7), 0)
+	require.Nil(t, err)
+	wides := make([]tokenchain.Payout, 71)
+	for i := range wides {
+		wides[i] = tokenchain.Payout{Account: payee(t, i), Amount: wide}
+	}
+	_, results, err := token.TransferMany(getAccount(0), wides)
+	assert.NotNil(t, err)
+	assert.Len(t, results, len(wides))
+	_, _, err = token.TransferMany(getAccount(0), wides[:70])
+	require.Nil(t, err)
+	for _, p := range wides[:70] {
+		assert.Equal(t, wide, token.Balance(p.Account))
+	}
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+}
@@ -2,27 +2,47 @@ package tokenchain
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
 	"errors"
 	"math/big"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/hectorchu/gonano/rpc"
 	"github.com/hectorchu/gonano/util"
 	"github.com/hectorchu/gonano/wallet"
+	"github.com/hectorchu/gonano/wallet/ed25519"
 )
 
+// DefaultPollInterval is the interval at which a chain polls the node
+// while waiting.
+const DefaultPollInterval = 5 * time.Second
+
+// parseBatchSize is the number of chain blocks fetched at a time by Parse.
+const parseBatchSize = 1000
+
 // Chain represents a token chain.
 type Chain struct {
-	seed     []byte
-	w        *wallet.Wallet
-	a        *wallet.Account
-	frontier rpc.BlockHash
-	tokens   map[uint32]*Token
-	swaps    map[uint32]*Swap
+	seed         []byte
+	key          ed25519.PrivateKey
+	address      string
+	node         NodeBackend
+	pollInterval time.Duration
+	progress     func(height, total uint32)
+	handlers     []func(Event)
+	frontier     rpc.BlockHash
+	tokens       map[uint32]*Token
+	swaps        map[uint32]*Swap
+	htlcs        map[uint32]*HTLC
+	collections  map[uint32]*Collection
+	multiTokens  map[uint32]*MultiToken
+	height       uint32
+	parsing      bool
+	blocks       map[string]*rpc.BlockInfo
 }
 
 // NewChain initializes a new chain.
@@ -31,20 +51,22 @@ func NewChain(rpcURL string) (c *Chain, err error) {
 	if _, err = rand.Read(seed); err != nil {
 		return
 	}
-	if c, err = NewChainFromSeed(seed, rpcURL); err != nil {
-		return
-	}
-	return c, setData(c.a, seed)
+	return NewChainFromSeed(seed, rpcURL)
 }
 
 // LoadChain loads a chain at an address.
 func LoadChain(address, rpcURL string) (c *Chain, err error) {
-	client := rpc.Client{URL: rpcURL}
-	info, err := client.AccountInfo(address)
+	return LoadChainWithBackend(address, &rpc.Client{URL: rpcURL})
+}
+
+// LoadChainWithBackend loads a chain at an address, querying and
+// publishing blocks via the backend.
+func LoadChainWithBackend(address string, node NodeBackend) (c *Chain, err error) {
+	info, err := node.AccountInfo(address)
 	if err != nil {
 		return
 	}
-	block, err := client.BlockInfo(info.OpenBlock)
+	block, err := node.BlockInfo(info.OpenBlock)
 	if err != nil {
 		return
 	}
@@ -52,7 +74,7 @@ func LoadChain(address, rpcURL string) (c *Chain, err error) {
 	if err != nil {
 		return
 	}
-	if c, err = NewChainFromSeed(seed, rpcURL); err != nil {
+	if c, err = NewChainFromSeedWithBackend(seed, node); err != nil {
 		return
 	}
 	if c.Address() != address {
@@ -63,85 +85,147 @@ func LoadChain(address, rpcURL string) (c *Chain, err error) {
 
 // Address returns the address of the chain.
 func (c *Chain) Address() string {
-	return c.a.Address()
+	return c.address
 }
 
 // NewChainFromSeed initializes a new chain from a seed.
 func NewChainFromSeed(seed []byte, rpcURL string) (c *Chain, err error) {
-	w, err := wallet.NewWallet(seed)
+	return NewChainFromSeedWithBackend(seed, &rpc.Client{URL: rpcURL})
+}
+
+// NewChainFromSeedWithBackend initializes a new chain from a seed, querying
+// and publishing blocks via the backend.
+func NewChainFromSeedWithBackend(seed []byte, node NodeBackend) (c *Chain, err error) {
+	pubkey, key, err := deriveKey(seed)
 	if err != nil {
 		return
 	}
-	w.RPC.URL = rpcURL
-	a, err := w.NewAccount(nil)
+	address, err := util.PubkeyToAddress(pubkey)
 	if err != nil {
 		return
 	}
 	c = &Chain{
-		seed:   seed,
-		w:      w,
-		a:      a,
-		tokens: make(map[uint32]*Token),
-		swaps:  make(map[uint32]*Swap),
+		seed:         seed,
+		key:          key,
+		address:      address,
+		node:         node,
+		pollInterval: DefaultPollInterval,
+		tokens:       make(map[uint32]*Token),
+		swaps:        make(map[uint32]*Swap),
+		htlcs:        make(map[uint32]*HTLC),
+		collections:  make(map[uint32]*Collection),
+		multiTokens:  make(map[uint32]*MultiToken),
 	}
 	return
 }
 
-// WaitForOpen waits for the open block.
+// WaitForOpen waits for the open block, polling the node at the poll
+// interval.
 func (c *Chain) WaitForOpen() (err error) {
+	return c.WaitForOpenContext(context.Background())
+}
+
+// WaitForOpenContext is like WaitForOpen but returns when ctx is done.
+func (c *Chain) WaitForOpenContext(ctx context.Context) (err error) {
 	for {
-		balance, pending, err := c.a.Balance()
-		switch {
-		case err != nil:
-			return err
-		case balance.Sign() > 0:
+		if info, err := c.backend(ctx).AccountInfo(c.Address()); err == nil && info.Balance.Sign() > 0 {
 			return nil
-		case pending.Sign() > 0:
-			if err = c.a.ReceivePendings(); err != nil {
+		}
+		pending, err := c.backend(ctx).AccountsPending([]string{c.Address()}, -1)
+		if err != nil {
+			return err
+		}
+		if len(pending[c.Address()]) > 0 {
+			if err = c.receivePendings(ctx); err != nil {
 				return err
 			}
-		default:
-			time.Sleep(5 * time.Second)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.pollInterval):
 		}
 	}
 }
 
-func (c *Chain) rpc() *rpc.Client {
-	return &c.w.RPC
+func (c *Chain) send(a *wallet.Account, destinations []string, m message) (hash rpc.BlockHash, err error) {
+	return c.sendContext(context.Background(), a, destinations, m)
+}
+
+func (c *Chain) sendContext(ctx context.Context, a *wallet.Account, destinations []string, m message) (hash rpc.BlockHash, err error) {
+	amounts := make([]*big.Int, len(destinations))
+	for i := range amounts {
+		amounts[i] = big.NewInt(1)
+	}
+	return c.sendAmounts(ctx, a, destinations, amounts, m)
 }
 
-func (c *Chain) send(a *wallet.Account, destination *string, m message) (hash rpc.BlockHash, err error) {
-	if err = setData(a, m.serialize()); err != nil {
+// sendAmounts sends a message whose destination sends carry the given
+// amounts of raw.
+func (c *Chain) sendAmounts(ctx context.Context, a *wallet.Account, destinations []string, amounts []*big.Int, m message) (hash rpc.BlockHash, err error) {
+	if err = c.checkNotParsing(); err != nil {
 		return
 	}
-	if destination != nil {
-		if _, err = a.Send(*destination, big.NewInt(1)); err != nil {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if m, ok := m.(extendedMessage); ok {
+		data, err := m.extension()
+		if err != nil {
+			return nil, err
+		}
+		chunks, err := splitExtension(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
+			if err = c.changeData(ctx, a, chunk); err != nil {
+				return nil, err
+			}
+		}
+	}
+	data := m.serialize()
+	for i, destination := range destinations {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if _, err = c.publishSend(ctx, a, data, destination, amounts[i]); err != nil {
 			return
 		}
 	}
-	if hash, err = a.Send(c.Address(), big.NewInt(1)); err != nil {
+	if err = ctx.Err(); err != nil {
 		return
 	}
-	if hash, err = c.confirm(hash); err != nil {
+	if hash, err = c.publishSend(ctx, a, data, c.Address(), big.NewInt(1)); err != nil {
 		return
 	}
-	return hash, c.Parse()
+	if hash, err = c.confirm(ctx, hash); err != nil {
+		return
+	}
+	return hash, c.ParseContext(ctx)
 }
 
-func (c *Chain) confirm(link rpc.BlockHash) (hash rpc.BlockHash, err error) {
+func (c *Chain) confirm(ctx context.Context, link rpc.BlockHash) (hash rpc.BlockHash, err error) {
 	for {
-		if hash, err = c.a.ReceivePending(link); err != nil {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if hash, err = c.receive(ctx, link); err != nil {
 			switch err.Error() {
 			case "Fork":
 				continue
 			case "Unreceivable":
 				var hashes []rpc.BlockHash
-				if hashes, err = c.rpc().Successors(c.frontier, -1); err != nil {
+				if hashes, err = c.backend(ctx).Successors(c.frontier, -1); err != nil {
 					return
 				}
 				for _, hash = range hashes[1:] {
 					var block rpc.BlockInfo
-					if block, err = c.rpc().BlockInfo(hash); err != nil {
+					if block, err = c.backend(ctx).BlockInfo(hash); err != nil {
 						return
 					}
 					if bytes.Equal(block.Contents.Link, link) {
@@ -154,32 +238,95 @@ func (c *Chain) confirm(link rpc.BlockHash) (hash rpc.BlockHash, err error) {
 	}
 }
 
-// Parse parses the chain for tokens.
+// SetProgress sets a function called by Parse after each batch of blocks
+// with the height parsed up to and the height of the chain's frontier.
+func (c *Chain) SetProgress(progress func(height, total uint32)) {
+	c.progress = progress
+}
+
+// Parse parses the chain for tokens. Blocks are fetched from the node in
+// batches, in chain order.
 func (c *Chain) Parse() (err error) {
-	if c.frontier == nil {
-		info, err := c.rpc().AccountInfo(c.Address())
+	return c.ParseContext(context.Background())
+}
+
+// ParseContext is like Parse but stops when ctx is done. Blocks parsed
+// before then are kept.
+func (c *Chain) ParseContext(ctx context.Context) (err error) {
+	if err = c.checkNotParsing(); err != nil {
+		return
+	}
+	c.parsing = true
+	defer func() { c.parsing = false }()
+	var total uint32
+	if c.frontier == nil || c.progress != nil {
+		info, err := c.backend(ctx).AccountInfo(c.Address())
 		if err != nil {
 			return err
 		}
-		c.frontier = info.OpenBlock
+		if c.frontier == nil {
+			c.frontier = info.OpenBlock
+		}
+		total = uint32(info.BlockCount)
 	}
-	hashes, err := c.rpc().Successors(c.frontier, -1)
+	for {
+		hashes, err := c.backend(ctx).Successors(c.frontier, parseBatchSize+1)
+		if err != nil {
+			return err
+		}
+		if len(hashes) <= 1 {
+			return nil
+		}
+		if err = c.parseBatch(ctx, hashes[1:]); err != nil {
+			return err
+		}
+		if c.progress != nil {
+			c.progress(c.height, total)
+		}
+	}
+}
+
+// parseBatch processes consecutive chain blocks, fetching them, the sends
+// they receive and the blocks preceding those sends in bulk.
+func (c *Chain) parseBatch(ctx context.Context, hashes []rpc.BlockHash) (err error) {
+	blocks, err := c.backend(ctx).BlocksInfo(hashes)
 	if err != nil {
 		return
 	}
-	for _, hash := range hashes[1:] {
-		info, err := c.rpc().BlockInfo(hash)
-		if err != nil {
-			return err
+	infos := make([]*rpc.BlockInfo, len(hashes))
+	var links []rpc.BlockHash
+	for i, hash := range hashes {
+		if infos[i] = blocks[hash.String()]; infos[i] == nil {
+			return errors.New("Block not found")
+		}
+		if infos[i].Subtype == "receive" {
+			links = append(links, infos[i].Contents.Link)
 		}
-		if info.Subtype != "receive" {
+	}
+	sends := make(map[string]*rpc.BlockInfo)
+	if len(links) > 0 {
+		if sends, err = c.backend(ctx).BlocksInfo(links); err != nil {
+			return
+		}
+	}
+	defer func() { c.blocks = nil }()
+	if err = c.prefetch(ctx, sends); err != nil {
+		return
+	}
+	for i, hash := range hashes {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		c.setClock(*infos[i])
+		if infos[i].Subtype != "receive" {
 			c.frontier = hash
 			continue
 		}
-		height := uint32(info.Height)
-		info, err = c.rpc().BlockInfo(info.Contents.Link)
-		if err != nil {
-			return err
+		height := c.height
+		c.expire(hash, height)
+		info := sends[infos[i].Contents.Link.String()]
+		if info == nil {
+			return errors.New("Block not found")
 		}
 		data, err := util.AddressToPubkey(info.Contents.Representative)
 		if err != nil {
@@ -190,7 +337,7 @@ func (c *Chain) Parse() (err error) {
 			c.frontier = hash
 			continue
 		}
-		if _, err = m.process(c, hash, height, info); err != nil {
+		if _, err = m.process(ctx, c, hash, height, *info); err != nil {
 			return err
 		}
 		c.frontier = hash
@@ -198,22 +345,153 @@ func (c *Chain) Parse() (err error) {
 	return
 }
 
-func (c *Chain) getDestination(block *rpc.Block) (account string, valid bool, err error) {
-	info, err := c.rpc().BlockInfo(block.Previous)
-	if err != nil {
-		return
+// prefetch fetches the destination sends and continuation blocks preceding
+// message sends level by level, caching them for the rest of the batch.
+func (c *Chain) prefetch(ctx context.Context, sends map[string]*rpc.BlockInfo) (err error) {
+	c.blocks = make(map[string]*rpc.BlockInfo)
+	reps := make(map[string]string)
+	var hashes []rpc.BlockHash
+	add := func(hash rpc.BlockHash, rep string) {
+		if bytes.Count(hash, []byte{0}) == len(hash) {
+			return
+		}
+		if _, ok := reps[hash.String()]; ok {
+			return
+		}
+		reps[hash.String()] = rep
+		hashes = append(hashes, hash)
+	}
+	for _, info := range sends {
+		if data, err := util.AddressToPubkey(info.Contents.Representative); err == nil && string(data[:3]) == "TKN" {
+			add(info.Contents.Previous, info.Contents.Representative)
+		}
 	}
-	if info.Subtype != "send" {
+	for len(hashes) > 0 {
+		blocks, err := c.backend(ctx).BlocksInfo(hashes)
+		if err != nil {
+			return err
+		}
+		level := hashes
+		hashes = nil
+		for _, hash := range level {
+			info := blocks[hash.String()]
+			if info == nil {
+				continue
+			}
+			c.blocks[hash.String()] = info
+			rep := reps[hash.String()]
+			switch info.Subtype {
+			case "send":
+				if info.Contents.Representative == rep {
+					add(info.Contents.Previous, rep)
+				}
+			case "change":
+				data, err := util.AddressToPubkey(info.Contents.Representative)
+				if err == nil && string(data[:3]) == "TKN" && data[3] == continuationOp && data[4]&continuationFirst == 0 {
+					add(info.Contents.Previous, rep)
+				}
+			}
+		}
+	}
+	return
+}
+
+// blockInfo gets a block from the batch cache, or else from the node.
+func (c *Chain) blockInfo(ctx context.Context, hash rpc.BlockHash) (info rpc.BlockInfo, err error) {
+	if info, ok := c.blocks[hash.String()]; ok {
+		return *info, nil
+	}
+	return c.backend(ctx).BlockInfo(hash)
+}
+
+func (c *Chain) getDestination(ctx context.Context, block *rpc.Block) (account string, valid bool, err error) {
+	accounts, valid, err := c.getDestinations(ctx, block, 1)
+	if !valid {
 		return
 	}
-	if info.Contents.Representative != block.Representative {
+	return accounts[0], true, nil
+}
+
+func (c *Chain) getDestinations(ctx context.Context, block *rpc.Block, n int) (accounts []string, valid bool, err error) {
+	sends, valid, err := c.getSends(ctx, block, n)
+	if !valid {
 		return
 	}
-	return info.Contents.LinkAsAccount, true, nil
+	accounts = make([]string, n)
+	for i, info := range sends {
+		accounts[i] = info.Contents.LinkAsAccount
+	}
+	return accounts, true, nil
+}
+
+// getSends gets the n destination sends preceding a message block.
+func (c *Chain) getSends(ctx context.Context, block *rpc.Block, n int) (sends []rpc.BlockInfo, valid bool, err error) {
+	sends = make([]rpc.BlockInfo, n)
+	for i := n - 1; i >= 0; i-- {
+		info, err := c.blockInfo(ctx, block.Previous)
+		if err != nil {
+			return nil, false, err
+		}
+		if info.Subtype != "send" {
+			return nil, false, nil
+		}
+		if info.Contents.Representative != block.Representative {
+			return nil, false, nil
+		}
+		sends[i] = info
+		block = info.Contents
+	}
+	return sends, true, nil
+}
+
+// getExtension reassembles the extension data carried in the continuation
+// blocks preceding a message block and its n destination sends.
+func (c *Chain) getExtension(ctx context.Context, block *rpc.Block, n int) (data []byte, valid bool, err error) {
+	hash := block.Previous
+	for i := 0; i < n; i++ {
+		info, err := c.blockInfo(ctx, hash)
+		if err != nil {
+			return nil, false, err
+		}
+		hash = info.Contents.Previous
+	}
+	var chunks [][]byte
+	for seq := 0; ; seq++ {
+		if seq == continuationFirst || bytes.Count(hash, []byte{0}) == len(hash) {
+			return
+		}
+		info, err := c.blockInfo(ctx, hash)
+		if err != nil {
+			return nil, false, err
+		}
+		if info.Subtype != "change" {
+			return nil, false, nil
+		}
+		chunk, err := util.AddressToPubkey(info.Contents.Representative)
+		if err != nil {
+			return nil, false, err
+		}
+		if string(chunk[:3]) != "TKN" || chunk[3] != continuationOp || int(chunk[4]&^continuationFirst) != seq {
+			return nil, false, nil
+		}
+		chunks = append(chunks, chunk[5:])
+		if chunk[4]&continuationFirst != 0 {
+			break
+		}
+		hash = info.Contents.Previous
+	}
+	for i := len(chunks) - 1; i >= 0; i-- {
+		data = append(data, chunks[i]...)
+	}
+	return data, true, nil
 }
 
 func (c *Chain) getHeight(hash rpc.BlockHash) (height uint32, err error) {
-	info, err := c.rpc().BlockInfo(hash)
+	return c.getHeightContext(context.Background(), hash)
+}
+
+func (c *Chain) getHeightContext(ctx context.Context, hash rpc.BlockHash) (height uint32, err error) {
+	info, err := c.backend(ctx).BlockInfo(hash)
 	if err != nil {
 		return
 	}
@@ -225,6 +503,55 @@ func (c *Chain) getHeight(hash rpc.BlockHash) (height uint32, err error) {
 	return
 }
 
+// setClock sets the chain's height to that of a chain block.
+func (c *Chain) setClock(info rpc.BlockInfo) {
+	c.height = uint32(info.Height)
+}
+
+// checkNotParsing guards against ops and event handlers calling back into
+// the chain while it is being parsed.
+func (c *Chain) checkNotParsing() (err error) {
+	if c.parsing {
+		err = errors.New("Chain is being parsed")
+	}
+	return
+}
+
+// now returns the height at which locks are evaluated. While parsing this
+// is that of the block being processed, otherwise that expected of the next
+// block. Locks are never evaluated against block timestamps, which are local
+// to each node.
+func (c *Chain) now() uint32 {
+	if c.parsing {
+		return c.height
+	}
+	return c.height + 1
+}
+
+// expire cancels the swaps and times out the HTLCs that have expired at
+// the chain block. Swaps are cancelled in chain order so that their events
+// are emitted deterministically.
+func (c *Chain) expire(hash rpc.BlockHash, height uint32) {
+	var expired []uint32
+	for h, s := range c.swaps {
+		if s.expiry.expired(height) {
+			expired = append(expired, h)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+	for _, h := range expired {
+		s := c.swaps[h]
+		s.cancel()
+		delete(c.swaps, h)
+		c.emit(&SwapCancelled{s.event(hash, height, "")})
+	}
+	for _, h := range c.htlcs {
+		if h.Active() && height >= h.timeout {
+			h.expired = true
+		}
+	}
+}
+
 // Tokens gets the chain's tokens.
 func (c *Chain) Tokens() (tokens map[string]*Token) {
 	tokens = make(map[string]*Token)
@@ -260,6 +587,12 @@ func (c *Chain) LoadState(db *sql.DB) (err error) {
 		seed     = strings.ToUpper(hex.EncodeToString(c.seed))
 		frontier string
 	)
+	if err = c.checkNotParsing(); err != nil {
+		return
+	}
+	if err = migrateDB(db); err != nil {
+		return
+	}
 	err = db.QueryRow("SELECT frontier FROM chains WHERE seed = ?", seed).Scan(&frontier)
 	if err != nil {
 		return
@@ -268,6 +601,11 @@ func (c *Chain) LoadState(db *sql.DB) (err error) {
 	if err != nil {
 		return
 	}
+	info, err := c.backend(context.Background()).BlockInfo(c.frontier)
+	if err != nil {
+		return
+	}
+	c.setClock(info)
 	rows, err := db.Query("SELECT hash, height FROM tokens WHERE chain = ?", c.Address())
 	if err != nil {
 		return
@@ -291,6 +629,110 @@ func (c *Chain) LoadState(db *sql.DB) (err error) {
 		}
 		c.tokens[height] = t
 	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	rows, err = db.Query("SELECT hash, height FROM swaps WHERE chain = ? AND active = 1", c.Address())
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			hashStr string
+			height  uint32
+		)
+		if err := rows.Scan(&hashStr, &height); err != nil {
+			return err
+		}
+		hash, err := hex.DecodeString(hashStr)
+		if err != nil {
+			return err
+		}
+		s := &Swap{c: c, hash: hash}
+		if err = s.loadState(db); err != nil {
+			return err
+		}
+		c.swaps[height] = s
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	rows, err = db.Query("SELECT hash, height FROM htlcs WHERE chain = ?", c.Address())
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			hashStr string
+			height  uint32
+		)
+		if err := rows.Scan(&hashStr, &height); err != nil {
+			return err
+		}
+		hash, err := hex.DecodeString(hashStr)
+		if err != nil {
+			return err
+		}
+		h := &HTLC{c: c, hash: hash}
+		if err = h.loadState(db); err != nil {
+			return err
+		}
+		c.htlcs[height] = h
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	rows, err = db.Query("SELECT hash, height FROM collections WHERE chain = ?", c.Address())
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			hashStr string
+			height  uint32
+		)
+		if err := rows.Scan(&hashStr, &height); err != nil {
+			return err
+		}
+		hash, err := hex.DecodeString(hashStr)
+		if err != nil {
+			return err
+		}
+		col := &Collection{c: c, hash: hash}
+		if err = col.loadState(db); err != nil {
+			return err
+		}
+		c.collections[height] = col
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	rows, err = db.Query("SELECT hash, height FROM multi_tokens WHERE chain = ?", c.Address())
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			hashStr string
+			height  uint32
+		)
+		if err := rows.Scan(&hashStr, &height); err != nil {
+			return err
+		}
+		hash, err := hex.DecodeString(hashStr)
+		if err != nil {
+			return err
+		}
+		mt := &MultiToken{c: c, hash: hash}
+		if err = mt.loadState(db); err != nil {
+			return err
+		}
+		c.multiTokens[height] = mt
+	}
 	return rows.Err()
 }
 
@@ -301,6 +743,9 @@ func (c *Chain) SaveState(db *sql.DB) (err error) {
 		frontier     = strings.ToUpper(hex.EncodeToString(c.frontier))
 		prevFrontier string
 	)
+	if err = c.checkNotParsing(); err != nil {
+		return
+	}
 	err = db.QueryRow("SELECT frontier FROM chains WHERE seed = ?", seed).Scan(&prevFrontier)
 	if err == nil && frontier == prevFrontier {
 		return
@@ -309,8 +754,7 @@ func (c *Chain) SaveState(db *sql.DB) (err error) {
 	if err != nil {
 		return
 	}
-	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS chains (seed TEXT PRIMARY KEY, frontier TEXT)`)
-	if err != nil {
+	if err = migrate(tx); err != nil {
 		tx.Rollback()
 		return
 	}
@@ -325,5 +769,34 @@ func (c *Chain) SaveState(db *sql.DB) (err error) {
 			return
 		}
 	}
+	_, err = tx.Exec("UPDATE swaps SET active = 0 WHERE chain = ?", c.Address())
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+	for height, s := range c.swaps {
+		if err = s.saveState(tx, height); err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	for height, h := range c.htlcs {
+		if err = h.saveState(tx, height); err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	for height, col := range c.collections {
+		if err = col.saveState(tx, height); err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	for height, mt := range c.multiTokens {
+		if err = mt.saveState(tx, height); err != nil {
+			tx.Rollback()
+			return
+		}
+	}
 	return tx.Commit()
 }
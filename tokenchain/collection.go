@@ -0,0 +1,273 @@
+package tokenchain
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+const contentHashLen = 32
+
+// Collection represents a collection of non-fungible items. Items are
+// minted by the issuer, each with a unique ID and the hash of its content.
+type Collection struct {
+	c      *Chain
+	hash   rpc.BlockHash
+	issuer string
+	name   string
+	items  map[uint64]*item
+}
+
+type item struct {
+	owner       string
+	contentHash []byte
+}
+
+// Hash returns the block hash of the collection.
+func (col *Collection) Hash() rpc.BlockHash {
+	return col.hash
+}
+
+// Issuer returns the account that created the collection.
+func (col *Collection) Issuer() string {
+	return col.issuer
+}
+
+// Name returns the collection name.
+func (col *Collection) Name() string {
+	return col.name
+}
+
+// Owner gets the owner of an item.
+func (col *Collection) Owner(id uint64) (owner string, err error) {
+	it, ok := col.items[id]
+	if !ok {
+		return "", errors.New("Item not found")
+	}
+	return it.owner, nil
+}
+
+// ContentHash gets the content hash of an item.
+func (col *Collection) ContentHash(id uint64) (contentHash []byte, err error) {
+	it, ok := col.items[id]
+	if !ok {
+		return nil, errors.New("Item not found")
+	}
+	return append([]byte(nil), it.contentHash...), nil
+}
+
+// Items gets the IDs of all items in ascending order.
+func (col *Collection) Items() (ids []uint64) {
+	for id := range col.items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return
+}
+
+// ItemsOf gets the IDs of the items owned by account in ascending order.
+func (col *Collection) ItemsOf(account string) (ids []uint64) {
+	for id, it := range col.items {
+		if it.owner == account {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return
+}
+
+// CollectionGenesis initializes a new collection on a chain.
+func CollectionGenesis(c *Chain, a *wallet.Account, name string) (col *Collection, err error) {
+	if err = c.Parse(); err != nil {
+		return
+	}
+	hash, err := c.send(a, nil, &collectionMessage{name: name})
+	if err != nil {
+		return
+	}
+	return c.Collection(hash)
+}
+
+func (m *collectionMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	c.collections[height] = &Collection{
+		c:      c,
+		hash:   hash,
+		issuer: info.BlockAccount,
+		name:   m.name,
+		items:  make(map[uint64]*item),
+	}
+	return true, nil
+}
+
+// Mint mints a new item with an ID and content hash to an account.
+func (col *Collection) Mint(a *wallet.Account, account string, id uint64, contentHash []byte) (hash rpc.BlockHash, err error) {
+	if err = col.c.Parse(); err != nil {
+		return
+	}
+	if err = col.checkMint(a.Address(), id); err != nil {
+		return
+	}
+	height, err := col.c.getHeight(col.hash)
+	if err != nil {
+		return
+	}
+	return col.c.send(a, []string{account}, &itemMintMessage{
+		collection:  height,
+		id:          id,
+		contentHash: contentHash,
+	})
+}
+
+func (col *Collection) checkMint(account string, id uint64) (err error) {
+	if account != col.issuer {
+		return errors.New("Must mint with issuer account")
+	}
+	if _, ok := col.items[id]; ok {
+		err = errors.New("Item already exists")
+	}
+	return
+}
+
+func (m *itemMintMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	col, ok := c.collections[m.collection]
+	if !ok {
+		return
+	}
+	if col.checkMint(info.BlockAccount, m.id) != nil {
+		return
+	}
+	owner, valid, err := c.getDestination(ctx, info.Contents)
+	if !valid {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 1)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil {
+		return false, nil
+	}
+	col.items[m.id] = &item{owner: owner, contentHash: m.contentHash}
+	return
+}
+
+// Transfer transfers an item to another account.
+func (col *Collection) Transfer(a *wallet.Account, account string, id uint64) (hash rpc.BlockHash, err error) {
+	if err = col.c.Parse(); err != nil {
+		return
+	}
+	if err = col.checkTransfer(a.Address(), id); err != nil {
+		return
+	}
+	height, err := col.c.getHeight(col.hash)
+	if err != nil {
+		return
+	}
+	return col.c.send(a, []string{account}, &itemTransferMessage{
+		collection: height,
+		id:         id,
+	})
+}
+
+func (col *Collection) checkTransfer(account string, id uint64) (err error) {
+	owner, err := col.Owner(id)
+	if err != nil {
+		return
+	}
+	if account != owner {
+		err = errors.New("Must transfer with owner account")
+	}
+	return
+}
+
+func (m *itemTransferMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	col, ok := c.collections[m.collection]
+	if !ok {
+		return
+	}
+	if col.checkTransfer(info.BlockAccount, m.id) != nil {
+		return
+	}
+	destination, valid, err := c.getDestination(ctx, info.Contents)
+	if !valid {
+		return
+	}
+	col.items[m.id].owner = destination
+	return
+}
+
+// Collections gets the chain's collections.
+func (c *Chain) Collections() (collections map[string]*Collection) {
+	collections = make(map[string]*Collection)
+	for _, col := range c.collections {
+		collections[string(col.Hash())] = col
+	}
+	return
+}
+
+// Collection gets the collection at the specified block hash.
+func (c *Chain) Collection(hash rpc.BlockHash) (col *Collection, err error) {
+	for _, col = range c.collections {
+		if bytes.Equal(hash, col.Hash()) {
+			return
+		}
+	}
+	return nil, errors.New("Collection not found")
+}
+
+func (col *Collection) loadState(db *sql.DB) (err error) {
+	hash := strings.ToUpper(hex.EncodeToString(col.hash))
+	row := db.QueryRow("SELECT issuer, name FROM collections WHERE hash = ?", hash)
+	if err = row.Scan(&col.issuer, &col.name); err != nil {
+		return
+	}
+	col.items = make(map[uint64]*item)
+	rows, err := db.Query("SELECT id, owner, content_hash FROM collection_items WHERE hash = ?", hash)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id          int64
+			it          item
+			contentHash string
+		)
+		if err = rows.Scan(&id, &it.owner, &contentHash); err != nil {
+			return
+		}
+		if it.contentHash, err = hex.DecodeString(contentHash); err != nil {
+			return
+		}
+		col.items[uint64(id)] = &it
+	}
+	return rows.Err()
+}
+
+func (col *Collection) saveState(tx *sql.Tx, height uint32) (err error) {
+	hash := strings.ToUpper(hex.EncodeToString(col.hash))
+	if _, err = tx.Exec(
+		"REPLACE INTO collections (hash, chain, height, issuer, name) VALUES (?, ?, ?, ?, ?)",
+		hash, col.c.Address(), height, col.issuer, col.name,
+	); err != nil {
+		return
+	}
+	stmt, err := tx.Prepare("REPLACE INTO collection_items (hash, id, owner, content_hash) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	for id, it := range col.items {
+		if _, err = stmt.Exec(hash, int64(id), it.owner, strings.ToUpper(hex.EncodeToString(it.contentHash))); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package tokenchain_test
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertEqualCollections(t *testing.T, c1, c2 *tokenchain.Chain) {
+	collections1, collections2 := c1.Collections(), c2.Collections()
+	assert.Len(t, collections1, len(collections2))
+	for hash, col1 := range collections1 {
+		col2, ok := collections2[hash]
+		require.True(t, ok)
+		assert.Equal(t, col1.Name(), col2.Name())
+		assert.Equal(t, col1.Issuer(), col2.Issuer())
+		assert.Equal(t, col1.Items(), col2.Items())
+		for _, id := range col1.Items() {
+			owner1, _ := col1.Owner(id)
+			owner2, _ := col2.Owner(id)
+			assert.Equal(t, owner1, owner2)
+			contentHash1, _ := col1.ContentHash(id)
+			contentHash2, _ := col2.ContentHash(id)
+			assert.Equal(t, contentHash1, contentHash2)
+		}
+	}
+}
+
+func TestCollection(t *testing.T) {
+	var (
+		chain    = newChain(t)
+		issuer   = getAccount(0)
+		holder   = getAccount(1)
+		ticket1  = sha256.Sum256([]byte("ticket 1"))
+		ticket2  = sha256.Sum256([]byte("ticket 2"))
+		bigID    = uint64(1) << 63
+		col, err = tokenchain.CollectionGenesis(chain, issuer, "Concert tickets")
+	)
+	require.Nil(t, err)
+	assert.Equal(t, "Concert tickets", col.Name())
+	assert.Equal(t, issuer.Address(), col.Issuer())
+	_, err = col.Mint(holder, holder.Address(), 1, ticket1[:])
+	assert.NotNil(t, err)
+	_, err = col.Mint(issuer, holder.Address(), 1, ticket1[:3])
+	assert.NotNil(t, err)
+	_, err = col.Mint(issuer, holder.Address(), 1, ticket1[:])
+	require.Nil(t, err)
+	_, err = col.Mint(issuer, holder.Address(), 1, ticket2[:])
+	assert.NotNil(t, err)
+	_, err = col.Mint(issuer, issuer.Address(), bigID, ticket2[:])
+	require.Nil(t, err)
+	owner, err := col.Owner(1)
+	require.Nil(t, err)
+	assert.Equal(t, holder.Address(), owner)
+	contentHash, err := col.ContentHash(1)
+	require.Nil(t, err)
+	assert.Equal(t, ticket1[:], contentHash)
+	_, err = col.Owner(2)
+	assert.NotNil(t, err)
+	assert.Equal(t, []uint64{1}, col.ItemsOf(holder.Address()))
+	assert.Equal(t, []uint64{bigID}, col.ItemsOf(issuer.Address()))
+	_, err = col.Transfer(issuer, payee(t, 0), 1)
+	assert.NotNil(t, err)
+	_, err = col.Transfer(holder, payee(t, 0), 1)
+	require.Nil(t, err)
+	owner, err = col.Owner(1)
+	require.Nil(t, err)
+	assert.Equal(t, payee(t, 0), owner)
+	assert.Empty(t, col.ItemsOf(holder.Address()))
+	assert.Equal(t, []uint64{1, bigID}, col.Items())
+	assertEqualCollections(t, chain, loadChain(t, chain.Address()))
+	assertEqualCollections(t, chain, restoreChain(t, chain))
+}
@@ -0,0 +1,28 @@
+package tokenchain
+
+import (
+	"context"
+	"time"
+
+	"github.com/hectorchu/gonano/rpc"
+)
+
+// SetPollInterval sets the interval at which the chain polls the node
+// while waiting.
+func (c *Chain) SetPollInterval(d time.Duration) {
+	if d <= 0 {
+		d = DefaultPollInterval
+	}
+	c.pollInterval = d
+}
+
+// backend returns the node backend for requests made under ctx. An
+// *rpc.Client is copied so that its requests honor ctx without affecting
+// other chains sharing the client. Other backends cannot be interrupted,
+// so ctx is checked between their requests.
+func (c *Chain) backend(ctx context.Context) NodeBackend {
+	if client, ok := c.node.(*rpc.Client); ok {
+		return &rpc.Client{URL: client.URL, Ctx: ctx}
+	}
+	return c.node
+}
@@ -0,0 +1,117 @@
+package tokenchain_test
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForOpenContext(t *testing.T) {
+	chain, err := tokenchain.NewChain(rpcURL)
+	require.Nil(t, err)
+	chain.SetPollInterval(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, chain.WaitForOpenContext(ctx), context.DeadlineExceeded)
+	_, err = getAccount(0).Send(chain.Address(), big.NewInt(1))
+	require.Nil(t, err)
+	require.Nil(t, chain.WaitForOpenContext(context.Background()))
+}
+
+func TestContextCancel(t *testing.T) {
+	chain := newChain(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := tokenchain.TokenGenesisContext(ctx, chain, getAccount(0), "TOKEN", supply, 5)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, chain.Tokens())
+	assert.ErrorIs(t, chain.ParseContext(ctx), context.Canceled)
+	token, err := tokenchain.TokenGenesisContext(context.Background(), chain, getAccount(0), "TOKEN", supply, 5)
+	require.Nil(t, err)
+	_, err = token.TransferContext(ctx, getAccount(0), getAccount(1).Address(), big.NewInt(1000))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, supply, token.Balance(getAccount(0).Address()))
+	require.Nil(t, chain.Parse())
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+}
+
+func TestSwapContext(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token1  = genesis(t, chain, getAccount(0))
+		token2  = genesis(t, chain, getAccount(1))
+		amount1 = big.NewInt(1000)
+		amount2 = big.NewInt(2000)
+		ctx     = context.Background()
+	)
+	swap, err := tokenchain.ProposeSwapContext(ctx, chain, getAccount(0), getAccount(1).Address(), token1, amount1)
+	require.Nil(t, err)
+	_, err = swap.AcceptContext(ctx, getAccount(1), token2, amount2)
+	require.Nil(t, err)
+	_, err = swap.ConfirmContext(ctx, getAccount(0))
+	require.Nil(t, err)
+	assert.False(t, swap.Active())
+	assert.Equal(t, amount2, token2.Balance(getAccount(0).Address()))
+	swap, err = tokenchain.ProposeSwapContext(ctx, chain, getAccount(0), getAccount(1).Address(), token1, amount1)
+	require.Nil(t, err)
+	_, err = swap.CancelContext(ctx, getAccount(0))
+	require.Nil(t, err)
+	assert.False(t, swap.Active())
+}
+
+// newHangingNode starts a node that never answers.
+func newHangingNode(t *testing.T) (url string) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	t.Cleanup(func() {
+		close(done)
+		srv.Close()
+	})
+	return srv.URL
+}
+
+func TestContextHangingNode(t *testing.T) {
+	chain, err := tokenchain.NewChainFromSeedWithBackend(make([]byte, 32), &rpc.Client{URL: newHangingNode(t)})
+	require.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, chain.ParseContext(ctx), context.DeadlineExceeded)
+}
+
+func TestContextSharedBackend(t *testing.T) {
+	var (
+		backend = &rpc.Client{URL: rpcURL}
+		chain   = newChain(t)
+		wg      sync.WaitGroup
+	)
+	genesis(t, chain, getAccount(0))
+	chain1, err := tokenchain.LoadChainWithBackend(chain.Address(), backend)
+	require.Nil(t, err)
+	chain2, err := tokenchain.LoadChainWithBackend(chain.Address(), backend)
+	require.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			assert.ErrorIs(t, chain1.ParseContext(ctx), context.Canceled)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		require.Nil(t, chain2.ParseContext(context.Background()))
+	}
+	wg.Wait()
+	assertEqualChain(t, chain, chain2)
+}
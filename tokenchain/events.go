@@ -0,0 +1,90 @@
+package tokenchain
+
+import (
+	"math/big"
+
+	"github.com/hectorchu/gonano/rpc"
+)
+
+// Event represents a message accepted by the chain. It is one of
+// *TokenCreated, *Transferred, *SwapProposed, *SwapAccepted, *SwapConfirmed
+// or *SwapCancelled.
+type Event interface {
+	Block() EventBlock
+}
+
+// EventBlock identifies the chain block of an event.
+type EventBlock struct {
+	Hash   rpc.BlockHash
+	Height uint32
+}
+
+// Block returns the chain block of the event.
+func (b EventBlock) Block() EventBlock {
+	return b
+}
+
+// TokenCreated is emitted when a token is created.
+type TokenCreated struct {
+	EventBlock
+	Token  *Token
+	Issuer string
+	Supply *big.Int
+}
+
+// Transferred is emitted when tokens move between accounts, whether by a
+// plain, memo, vesting, batch or delegated transfer.
+type Transferred struct {
+	EventBlock
+	Token    *Token
+	From, To string
+	Amount   *big.Int
+	Memo     string
+}
+
+// SwapEvent holds the details common to swap events. Account is the
+// account that sent the message.
+type SwapEvent struct {
+	EventBlock
+	Swap        *Swap
+	Account     string
+	Left, Right SwapLeg
+}
+
+// SwapProposed is emitted when a swap, offer or swap for Nano is proposed.
+type SwapProposed struct{ SwapEvent }
+
+// SwapAccepted is emitted when a swap is accepted.
+type SwapAccepted struct{ SwapEvent }
+
+// SwapConfirmed is emitted when a swap is confirmed, a swap for Nano is
+// paid, or an offer is filled in whole or in part. For a fill, Account is
+// the filling account and the fill is the last of the swap's fills.
+type SwapConfirmed struct{ SwapEvent }
+
+// SwapCancelled is emitted when a swap is cancelled, or expires at the
+// chain block of the event, in which case Account is empty.
+type SwapCancelled struct{ SwapEvent }
+
+// Subscribe registers a handler called with each event as Parse accepts
+// messages. Handlers are called in chain order before Parse returns. Calls
+// from a handler that parse, send or save the chain fail.
+func (c *Chain) Subscribe(handler func(Event)) {
+	c.handlers = append(c.handlers, handler)
+}
+
+func (c *Chain) emit(e Event) {
+	for _, handler := range c.handlers {
+		handler(e)
+	}
+}
+
+func (s *Swap) event(hash rpc.BlockHash, height uint32, account string) SwapEvent {
+	return SwapEvent{
+		EventBlock: EventBlock{Hash: hash, Height: height},
+		Swap:       s,
+		Account:    account,
+		Left:       s.Left(),
+		Right:      s.Right(),
+	}
+}
@@ -0,0 +1,158 @@
+package tokenchain_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token1  = genesis(t, chain, getAccount(0))
+		token2  = genesis(t, chain, getAccount(1))
+		amount1 = big.NewInt(1000)
+		amount2 = big.NewInt(2000)
+	)
+	transfer, err := token1.TransferWithMemo(getAccount(0), getAccount(1).Address(), amount1, "INV-1")
+	require.Nil(t, err)
+	swap, err := tokenchain.ProposeSwap(chain, getAccount(0), getAccount(1).Address(), token1, amount1)
+	require.Nil(t, err)
+	_, err = swap.Accept(getAccount(1), token2, amount2)
+	require.Nil(t, err)
+	confirm, err := swap.Confirm(getAccount(0))
+	require.Nil(t, err)
+	swap2, err := tokenchain.ProposeSwap(chain, getAccount(0), getAccount(1).Address(), token1, amount1)
+	require.Nil(t, err)
+	_, err = swap2.Cancel(getAccount(1))
+	require.Nil(t, err)
+
+	chain2, err := tokenchain.LoadChain(chain.Address(), rpcURL)
+	require.Nil(t, err)
+	var events []tokenchain.Event
+	chain2.Subscribe(func(e tokenchain.Event) { events = append(events, e) })
+	require.Nil(t, chain2.Parse())
+	require.Len(t, events, 8)
+
+	created, ok := events[0].(*tokenchain.TokenCreated)
+	require.True(t, ok)
+	assert.Equal(t, token1.Hash(), created.Hash)
+	assert.Equal(t, getAccount(0).Address(), created.Issuer)
+	assert.Equal(t, supply, created.Supply)
+	height, err := chain.BlockHeight(token1.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, height, created.Height)
+	assert.IsType(t, &tokenchain.TokenCreated{}, events[1])
+
+	transferred, ok := events[2].(*tokenchain.Transferred)
+	require.True(t, ok)
+	assert.Equal(t, transfer, transferred.Hash)
+	assert.Equal(t, token1.Hash(), transferred.Token.Hash())
+	assert.Equal(t, getAccount(0).Address(), transferred.From)
+	assert.Equal(t, getAccount(1).Address(), transferred.To)
+	assert.Equal(t, amount1, transferred.Amount)
+	assert.Equal(t, "INV-1", transferred.Memo)
+
+	proposed, ok := events[3].(*tokenchain.SwapProposed)
+	require.True(t, ok)
+	assert.Equal(t, swap.Hash(), proposed.Hash)
+	assert.Equal(t, getAccount(0).Address(), proposed.Account)
+	assert.Equal(t, getAccount(1).Address(), proposed.Right.Account)
+	assert.Equal(t, amount1, proposed.Left.Amount)
+
+	accepted, ok := events[4].(*tokenchain.SwapAccepted)
+	require.True(t, ok)
+	assert.Equal(t, getAccount(1).Address(), accepted.Account)
+	assert.Equal(t, amount2, accepted.Right.Amount)
+	assert.Equal(t, proposed.Swap, accepted.Swap)
+
+	confirmed, ok := events[5].(*tokenchain.SwapConfirmed)
+	require.True(t, ok)
+	assert.Equal(t, confirm, confirmed.Hash)
+	assert.Equal(t, proposed.Swap, confirmed.Swap)
+
+	assert.IsType(t, &tokenchain.SwapProposed{}, events[6])
+	cancelled, ok := events[7].(*tokenchain.SwapCancelled)
+	require.True(t, ok)
+	assert.Equal(t, getAccount(1).Address(), cancelled.Account)
+	assert.Equal(t, swap2.Hash(), cancelled.Swap.Hash())
+
+	for i := 1; i < len(events); i++ {
+		assert.Greater(t, events[i].Block().Height, events[i-1].Block().Height)
+	}
+	assertEqualChain(t, chain, chain2)
+}
+
+func TestEventsFillAndExpiry(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token1  = genesis(t, chain, getAccount(0))
+		token2  = genesis(t, chain, getAccount(1))
+		amount1 = big.NewInt(1000)
+		amount2 = big.NewInt(2000)
+	)
+	offer, err := tokenchain.ProposeOffer(chain, getAccount(0), token1, amount1, token2, amount2, tokenchain.Expiry{})
+	require.Nil(t, err)
+	partial, err := offer.FillPartial(getAccount(1), big.NewInt(500))
+	require.Nil(t, err)
+	fill, err := offer.Fill(getAccount(1), big.NewInt(1000))
+	require.Nil(t, err)
+	height, err := chain.BlockHeight(fill)
+	require.Nil(t, err)
+	swap, err := tokenchain.ProposeSwapWithExpiry(chain, getAccount(0), getAccount(1).Address(), token1, amount1, tokenchain.Expiry{Height: height + 2})
+	require.Nil(t, err)
+	transfer, err := token1.Transfer(getAccount(0), getAccount(1).Address(), amount1)
+	require.Nil(t, err)
+	assert.False(t, swap.Active())
+
+	chain2, err := tokenchain.LoadChain(chain.Address(), rpcURL)
+	require.Nil(t, err)
+	var events []tokenchain.Event
+	chain2.Subscribe(func(e tokenchain.Event) { events = append(events, e) })
+	require.Nil(t, chain2.Parse())
+	require.Len(t, events, 8)
+
+	assert.IsType(t, &tokenchain.SwapProposed{}, events[2])
+	filled, ok := events[3].(*tokenchain.SwapConfirmed)
+	require.True(t, ok)
+	assert.Equal(t, partial, filled.Hash)
+	assert.Equal(t, getAccount(1).Address(), filled.Account)
+	filled, ok = events[4].(*tokenchain.SwapConfirmed)
+	require.True(t, ok)
+	assert.Equal(t, fill, filled.Hash)
+	assert.False(t, filled.Swap.Active())
+	require.Len(t, filled.Swap.Fills(), 2)
+
+	assert.IsType(t, &tokenchain.SwapProposed{}, events[5])
+	expired, ok := events[6].(*tokenchain.SwapCancelled)
+	require.True(t, ok)
+	assert.Equal(t, transfer, expired.Hash)
+	assert.Equal(t, swap.Hash(), expired.Swap.Hash())
+	assert.Equal(t, "", expired.Account)
+	transferred, ok := events[7].(*tokenchain.Transferred)
+	require.True(t, ok)
+	assert.Equal(t, transfer, transferred.Hash)
+	assertEqualChain(t, chain, chain2)
+}
+
+func TestEventsReentrant(t *testing.T) {
+	var (
+		chain = newChain(t)
+		token = genesis(t, chain, getAccount(0))
+		errs  []error
+	)
+	chain.Subscribe(func(e tokenchain.Event) {
+		_, err := token.Transfer(getAccount(0), getAccount(1).Address(), big.NewInt(1))
+		errs = append(errs, err, chain.Parse())
+	})
+	_, err := token.Transfer(getAccount(0), getAccount(1).Address(), big.NewInt(1000))
+	require.Nil(t, err)
+	require.Len(t, errs, 2)
+	for _, err := range errs {
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, big.NewInt(1000), token.Balance(getAccount(1).Address()))
+}
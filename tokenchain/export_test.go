@@ -0,0 +1,31 @@
+package tokenchain
+
+import (
+	"math/big"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+// SendOffer posts an offer message without the checks ProposeOffer makes,
+// so that tests can put invalid offers on-chain.
+func SendOffer(c *Chain, a *wallet.Account, t *Token, amount *big.Int, want *Token, minAmount *big.Int) (hash rpc.BlockHash, err error) {
+	m := &offerMessage{amount: amount, minAmount: minAmount}
+	if m.token, err = c.getHeight(t.hash); err != nil {
+		return
+	}
+	if m.want, err = c.getHeight(want.hash); err != nil {
+		return
+	}
+	return c.send(a, nil, m)
+}
+
+// SendFill posts a fill message for the offer at a hash without the checks
+// Fill makes.
+func SendFill(c *Chain, a *wallet.Account, offer rpc.BlockHash, amount *big.Int) (hash rpc.BlockHash, err error) {
+	m := &fillMessage{amount: amount}
+	if m.offer, err = c.getHeight(offer); err != nil {
+		return
+	}
+	return c.send(a, nil, m)
+}
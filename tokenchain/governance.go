@@ -0,0 +1,195 @@
+package tokenchain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/util"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+const (
+	adminPause = iota + 1
+	adminUnpause
+	adminFreeze
+	adminUnfreeze
+	adminSetAdmin
+)
+
+// Admin returns the account allowed to pause the token and freeze
+// accounts, or an empty string if the token is not governed.
+func (t *Token) Admin() string {
+	return t.admin
+}
+
+// Paused returns whether the token is paused.
+func (t *Token) Paused() bool {
+	return t.paused
+}
+
+// Frozen returns whether account is frozen.
+func (t *Token) Frozen(account string) bool {
+	return t.frozen[account]
+}
+
+// checkRestrictions checks that the token is not paused and that none of
+// the accounts are frozen. It is applied to the senders and recipients of
+// transfers and to the parties of swaps, offers and HTLC claims. Burns, HTLC
+// refunds and the settlement of swaps for Nano are not restricted, so that
+// holders can always destroy their tokens and escrow is never stranded.
+func (t *Token) checkRestrictions(accounts ...string) (err error) {
+	if t.paused {
+		return errors.New("Token is paused")
+	}
+	for _, account := range accounts {
+		if t.frozen[account] {
+			return errors.New("Account is frozen")
+		}
+	}
+	return
+}
+
+// Pause pauses the token, preventing transfers and swaps of it. Holders can
+// still burn it and escrow can still be released.
+func (t *Token) Pause(a *wallet.Account) (hash rpc.BlockHash, err error) {
+	return t.sendAdmin(a, adminPause, "")
+}
+
+// Unpause unpauses the token.
+func (t *Token) Unpause(a *wallet.Account) (hash rpc.BlockHash, err error) {
+	return t.sendAdmin(a, adminUnpause, "")
+}
+
+// Freeze freezes an account, preventing it from sending or receiving the token.
+func (t *Token) Freeze(a *wallet.Account, account string) (hash rpc.BlockHash, err error) {
+	return t.sendAdmin(a, adminFreeze, account)
+}
+
+// Unfreeze unfreezes an account.
+func (t *Token) Unfreeze(a *wallet.Account, account string) (hash rpc.BlockHash, err error) {
+	return t.sendAdmin(a, adminUnfreeze, account)
+}
+
+// SetAdmin hands the admin role to another account.
+func (t *Token) SetAdmin(a *wallet.Account, account string) (hash rpc.BlockHash, err error) {
+	return t.sendAdmin(a, adminSetAdmin, account)
+}
+
+func (t *Token) sendAdmin(a *wallet.Account, action byte, account string) (hash rpc.BlockHash, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if err = t.checkAdmin(a.Address()); err != nil {
+		return
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	var destinations []string
+	if account != "" {
+		if _, err = util.AddressToPubkey(account); err != nil {
+			return
+		}
+		destinations = []string{account}
+	}
+	return t.c.send(a, destinations, &adminMessage{
+		token:  height,
+		action: action,
+	})
+}
+
+func (t *Token) checkAdmin(account string) (err error) {
+	if t.admin == "" {
+		return errors.New("Token has no admin")
+	}
+	if account != t.admin {
+		err = errors.New("Must use admin account")
+	}
+	return
+}
+
+func (m *adminMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if t.checkAdmin(info.BlockAccount) != nil {
+		return
+	}
+	switch m.action {
+	case adminPause:
+		t.paused = true
+	case adminUnpause:
+		t.paused = false
+	case adminFreeze, adminUnfreeze, adminSetAdmin:
+		account, valid, err := c.getDestination(ctx, info.Contents)
+		if !valid {
+			return false, err
+		}
+		switch m.action {
+		case adminFreeze:
+			t.frozen[account] = true
+		case adminUnfreeze:
+			t.frozen[account] = false
+		case adminSetAdmin:
+			t.admin = account
+		}
+	default:
+		return
+	}
+	return true, nil
+}
+
+func (t *Token) loadGovernance(db *sql.DB, hash string) (err error) {
+	row := db.QueryRow("SELECT admin, paused FROM token_governance WHERE hash = ?", hash)
+	switch err = row.Scan(&t.admin, &t.paused); err {
+	case nil:
+	case sql.ErrNoRows:
+		err = nil
+	default:
+		return
+	}
+	t.frozen = make(map[string]bool)
+	rows, err := db.Query("SELECT account, frozen FROM token_frozen WHERE hash = ?", hash)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			account string
+			frozen  bool
+		)
+		if err = rows.Scan(&account, &frozen); err != nil {
+			return
+		}
+		t.frozen[account] = frozen
+	}
+	return rows.Err()
+}
+
+func (t *Token) saveGovernance(tx *sql.Tx, hash string) (err error) {
+	if t.admin == "" {
+		return
+	}
+	if _, err = tx.Exec(
+		"REPLACE INTO token_governance (hash, admin, paused) VALUES (?, ?, ?)",
+		hash, t.admin, t.paused,
+	); err != nil {
+		return
+	}
+	stmt, err := tx.Prepare("REPLACE INTO token_frozen (hash, account, frozen) VALUES (?, ?, ?)")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	for account, frozen := range t.frozen {
+		if _, err = stmt.Exec(hash, account, frozen); err != nil {
+			return
+		}
+	}
+	return
+}
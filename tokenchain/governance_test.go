@@ -0,0 +1,121 @@
+package tokenchain_test
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGovernance(t *testing.T) {
+	var (
+		chain  = newChain(t)
+		admin  = getAccount(0)
+		holder = getAccount(1)
+		amount = big.NewInt(1000)
+	)
+	_, err := genesis(t, chain, admin).Pause(admin)
+	assert.NotNil(t, err)
+	token, err := tokenchain.TokenGenesisWithOptions(chain, admin, "TOKEN", supply, 5, tokenchain.TokenOptions{Admin: true})
+	require.Nil(t, err)
+	assert.Equal(t, admin.Address(), token.Admin())
+	_, err = token.Transfer(admin, holder.Address(), new(big.Int).Mul(amount, big.NewInt(2)))
+	require.Nil(t, err)
+	_, err = token.Freeze(holder, admin.Address())
+	assert.NotNil(t, err)
+	_, err = token.Freeze(admin, "nano_invalid")
+	assert.NotNil(t, err)
+	_, err = token.Freeze(admin, holder.Address())
+	require.Nil(t, err)
+	assert.True(t, token.Frozen(holder.Address()))
+	_, err = token.Transfer(holder, admin.Address(), amount)
+	assert.NotNil(t, err)
+	_, err = token.Transfer(admin, holder.Address(), amount)
+	assert.NotNil(t, err)
+	_, err = token.Unfreeze(admin, holder.Address())
+	require.Nil(t, err)
+	assert.False(t, token.Frozen(holder.Address()))
+	_, err = token.Transfer(holder, admin.Address(), amount)
+	require.Nil(t, err)
+	_, err = token.Pause(admin)
+	require.Nil(t, err)
+	assert.True(t, token.Paused())
+	_, err = token.Transfer(admin, holder.Address(), amount)
+	assert.NotNil(t, err)
+	_, err = token.Burn(admin, big.NewInt(1))
+	require.Nil(t, err)
+	_, err = token.SetAdmin(admin, holder.Address())
+	require.Nil(t, err)
+	assert.Equal(t, holder.Address(), token.Admin())
+	_, err = token.Unpause(admin)
+	assert.NotNil(t, err)
+	_, err = token.Freeze(holder, admin.Address())
+	require.Nil(t, err)
+	assertEqualGovernance(t, token, chain, loadChain(t, chain.Address()))
+	assertEqualGovernance(t, token, chain, restoreChain(t, chain))
+	_, err = token.Unpause(holder)
+	require.Nil(t, err)
+	assert.False(t, token.Paused())
+	_, err = token.Transfer(admin, holder.Address(), amount)
+	assert.NotNil(t, err)
+	half := big.NewInt(500)
+	_, err = token.Transfer(holder, payee(t, 0), half)
+	require.Nil(t, err)
+	assert.Equal(t, half, token.Balance(payee(t, 0)))
+	swap, err := tokenchain.ProposeSwap(chain, holder, admin.Address(), token, half)
+	require.Nil(t, err)
+	_, err = swap.Accept(admin, genesis(t, chain, admin), amount)
+	require.Nil(t, err)
+	_, err = swap.Confirm(holder)
+	assert.NotNil(t, err)
+	_, err = token.Unfreeze(holder, admin.Address())
+	require.Nil(t, err)
+	balance := token.Balance(admin.Address())
+	_, err = swap.Confirm(holder)
+	require.Nil(t, err)
+	assert.Equal(t, balance.Add(balance, half), token.Balance(admin.Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+}
+
+func assertEqualGovernance(t *testing.T, t1 *tokenchain.Token, c1, c2 *tokenchain.Chain) {
+	assertEqualChain(t, c1, c2)
+	t2, err := c2.Token(t1.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, t1.Admin(), t2.Admin())
+	assert.Equal(t, t1.Paused(), t2.Paused())
+	for _, account := range []string{getAccount(0).Address(), getAccount(1).Address()} {
+		assert.Equal(t, t1.Frozen(account), t2.Frozen(account))
+	}
+}
+
+func TestGovernanceEscrow(t *testing.T) {
+	var (
+		chain    = newChain(t)
+		admin    = getAccount(0)
+		holder   = getAccount(1)
+		amount   = big.NewInt(1000)
+		preimage = []byte("0123456789abcdef0123456789abcdef")
+		hashlock = sha256.Sum256(preimage)
+	)
+	token, err := tokenchain.TokenGenesisWithOptions(chain, admin, "TOKEN", supply, 5, tokenchain.TokenOptions{Admin: true})
+	require.Nil(t, err)
+	height, err := chain.BlockHeight(token.Hash())
+	require.Nil(t, err)
+	htlc, err := tokenchain.LockHTLC(chain, admin, holder.Address(), token, amount, hashlock[:], height+3)
+	require.Nil(t, err)
+	_, err = token.Pause(admin)
+	require.Nil(t, err)
+	_, err = htlc.Claim(holder, preimage)
+	assert.NotNil(t, err)
+	_, err = htlc.Refund(admin)
+	require.Nil(t, err)
+	assert.False(t, htlc.Active())
+	assert.Equal(t, supply, token.Balance(admin.Address()))
+	_, err = token.Burn(admin, amount)
+	require.Nil(t, err)
+	assert.Equal(t, new(big.Int).Sub(supply, amount), token.Supply())
+	assertEqualGovernance(t, token, chain, loadChain(t, chain.Address()))
+}
@@ -0,0 +1,331 @@
+package tokenchain
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+const preimageLen = 32
+
+// HTLC represents a hash-time-locked escrow of tokens. The recipient can
+// claim the tokens by revealing the preimage of the SHA-256 hashlock until
+// the chain reaches the timeout height, after which the sender can refund
+// them.
+type HTLC struct {
+	c                 *Chain
+	hash              rpc.BlockHash
+	token             *Token
+	sender, recipient string
+	amount            *big.Int
+	hashlock          []byte
+	timeout           uint32
+	preimage          []byte
+	expired, refunded bool
+}
+
+// Hash returns the block hash of the HTLC.
+func (h *HTLC) Hash() rpc.BlockHash {
+	return h.hash
+}
+
+// Token returns the token held by the HTLC.
+func (h *HTLC) Token() *Token {
+	return h.token
+}
+
+// Sender returns the account that locked the tokens.
+func (h *HTLC) Sender() string {
+	return h.sender
+}
+
+// Recipient returns the account that may claim the tokens.
+func (h *HTLC) Recipient() string {
+	return h.recipient
+}
+
+// Amount returns the amount of tokens held by the HTLC.
+func (h *HTLC) Amount() *big.Int {
+	return new(big.Int).Set(h.amount)
+}
+
+// Hashlock returns the SHA-256 hash of the preimage.
+func (h *HTLC) Hashlock() []byte {
+	return append([]byte(nil), h.hashlock...)
+}
+
+// Timeout returns the chain height at which the HTLC times out.
+func (h *HTLC) Timeout() uint32 {
+	return h.timeout
+}
+
+// Preimage returns the preimage revealed by the claim, or nil if unclaimed.
+func (h *HTLC) Preimage() []byte {
+	if h.preimage == nil {
+		return nil
+	}
+	return append([]byte(nil), h.preimage...)
+}
+
+// Active returns whether the HTLC is neither claimed nor refunded.
+func (h *HTLC) Active() bool {
+	return h.preimage == nil && !h.refunded
+}
+
+// Expired returns whether the HTLC has timed out on-chain.
+func (h *HTLC) Expired() bool {
+	return h.expired
+}
+
+func (h *HTLC) timedOut() bool {
+	return h.expired || h.c.now() >= h.timeout
+}
+
+func (h *HTLC) claimable(account string, preimage []byte) bool {
+	return !h.timedOut() && h.checkClaim(account, preimage) == nil
+}
+
+func (h *HTLC) refundable(account string) bool {
+	return h.timedOut() && h.checkRefund(account) == nil
+}
+
+// LockHTLC locks an amount of tokens on-chain for a recipient under a
+// SHA-256 hashlock until the chain reaches the timeout height.
+func LockHTLC(c *Chain, a *wallet.Account, recipient string, t *Token, amount *big.Int, hashlock []byte, timeout uint32) (h *HTLC, err error) {
+	if err = c.Parse(); err != nil {
+		return
+	}
+	if err = t.checkBalance(a.Address(), amount); err != nil {
+		return
+	}
+	if err = t.checkRestrictions(a.Address(), recipient); err != nil {
+		return
+	}
+	if timeout == 0 {
+		return nil, errors.New("Timeout is required")
+	}
+	m := &htlcLockMessage{
+		amount:   amount,
+		hashlock: hashlock,
+		timeout:  timeout,
+	}
+	if m.token, err = c.getHeight(t.hash); err != nil {
+		return
+	}
+	hash, err := c.send(a, []string{recipient}, m)
+	if err != nil {
+		return
+	}
+	return c.HTLC(hash)
+}
+
+func (m *htlcLockMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if t.checkBalance(info.BlockAccount, m.amount) != nil {
+		return
+	}
+	recipient, valid, err := c.getDestination(ctx, info.Contents)
+	if !valid {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 1)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil || m.timeout == 0 || t.checkRestrictions(info.BlockAccount, recipient) != nil {
+		return false, nil
+	}
+	balance := t.Balance(info.BlockAccount)
+	t.setBalance(info.BlockAccount, balance.Sub(balance, m.amount))
+	c.htlcs[height] = &HTLC{
+		c:         c,
+		hash:      hash,
+		token:     t,
+		sender:    info.BlockAccount,
+		recipient: recipient,
+		amount:    m.amount,
+		hashlock:  m.hashlock,
+		timeout:   m.timeout,
+	}
+	return
+}
+
+// Claim claims the tokens for the recipient by revealing the preimage.
+func (h *HTLC) Claim(a *wallet.Account, preimage []byte) (hash rpc.BlockHash, err error) {
+	if err = h.c.Parse(); err != nil {
+		return
+	}
+	if err = h.checkClaim(a.Address(), preimage); err != nil {
+		return
+	}
+	if h.timedOut() {
+		return nil, errors.New("HTLC has expired")
+	}
+	height, err := h.c.getHeight(h.hash)
+	if err != nil {
+		return
+	}
+	return h.c.send(a, nil, &htlcClaimMessage{
+		htlc:     height,
+		preimage: preimage,
+	})
+}
+
+func (h *HTLC) checkClaim(account string, preimage []byte) (err error) {
+	if !h.Active() {
+		return errors.New("HTLC is inactive")
+	}
+	if h.expired {
+		return errors.New("HTLC has expired")
+	}
+	if account != h.recipient {
+		return errors.New("Must claim HTLC with recipient account")
+	}
+	if hash := sha256.Sum256(preimage); !bytes.Equal(hash[:], h.hashlock) {
+		return errors.New("Preimage does not match hashlock")
+	}
+	return h.token.checkRestrictions(h.sender, h.recipient)
+}
+
+func (m *htlcClaimMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	h, ok := c.htlcs[m.htlc]
+	if !ok {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 0)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil || h.checkClaim(info.BlockAccount, m.preimage) != nil {
+		return false, nil
+	}
+	balance := h.token.Balance(h.recipient)
+	h.token.setBalance(h.recipient, balance.Add(balance, h.amount))
+	h.preimage = m.preimage
+	return
+}
+
+// Refund returns the tokens to the sender after the timeout.
+func (h *HTLC) Refund(a *wallet.Account) (hash rpc.BlockHash, err error) {
+	if err = h.c.Parse(); err != nil {
+		return
+	}
+	if err = h.checkRefund(a.Address()); err != nil {
+		return
+	}
+	if !h.timedOut() {
+		return nil, errors.New("HTLC has not expired")
+	}
+	height, err := h.c.getHeight(h.hash)
+	if err != nil {
+		return
+	}
+	return h.c.send(a, nil, &htlcRefundMessage{htlc: height})
+}
+
+func (h *HTLC) checkRefund(account string) (err error) {
+	if !h.Active() {
+		return errors.New("HTLC is inactive")
+	}
+	if account != h.sender {
+		return errors.New("Must refund HTLC with sender account")
+	}
+	return
+}
+
+func (m *htlcRefundMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	h, ok := c.htlcs[m.htlc]
+	if !ok {
+		return
+	}
+	if h.checkRefund(info.BlockAccount) != nil || !h.expired {
+		return
+	}
+	balance := h.token.Balance(h.sender)
+	h.token.setBalance(h.sender, balance.Add(balance, h.amount))
+	h.refunded = true
+	return true, nil
+}
+
+// HTLCs gets the chain's HTLCs.
+func (c *Chain) HTLCs() (htlcs map[string]*HTLC) {
+	htlcs = make(map[string]*HTLC)
+	for _, h := range c.htlcs {
+		htlcs[string(h.Hash())] = h
+	}
+	return
+}
+
+// HTLC gets the HTLC at the specified block hash.
+func (c *Chain) HTLC(hash rpc.BlockHash) (h *HTLC, err error) {
+	for _, h = range c.htlcs {
+		if bytes.Equal(hash, h.Hash()) {
+			return
+		}
+	}
+	return nil, errors.New("HTLC not found")
+}
+
+func (h *HTLC) loadState(db *sql.DB) (err error) {
+	var (
+		token, amount      string
+		hashlock, preimage string
+		ok                 bool
+	)
+	row := db.QueryRow(`
+		SELECT token, sender, recipient, amount, hashlock, timeout_height,
+		preimage, expired, refunded
+		FROM htlcs WHERE hash = ?
+	`, strings.ToUpper(hex.EncodeToString(h.hash)))
+	if err = row.Scan(
+		&token, &h.sender, &h.recipient, &amount, &hashlock, &h.timeout,
+		&preimage, &h.expired, &h.refunded,
+	); err != nil {
+		return
+	}
+	hash, err := hex.DecodeString(token)
+	if err != nil {
+		return
+	}
+	if h.token, err = h.c.Token(hash); err != nil {
+		return
+	}
+	if h.amount, ok = new(big.Int).SetString(amount, 10); !ok {
+		return errors.New("Failed to parse amount from DB")
+	}
+	if h.hashlock, err = hex.DecodeString(hashlock); err != nil {
+		return
+	}
+	if preimage != "" {
+		if h.preimage, err = hex.DecodeString(preimage); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (h *HTLC) saveState(tx *sql.Tx, height uint32) (err error) {
+	_, err = tx.Exec(`
+		REPLACE INTO htlcs
+		(hash, chain, height, token, sender, recipient, amount, hashlock, timeout_height,
+		preimage, expired, refunded)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		strings.ToUpper(hex.EncodeToString(h.hash)), h.c.Address(), height,
+		strings.ToUpper(hex.EncodeToString(h.token.hash)), h.sender, h.recipient, h.amount.String(),
+		strings.ToUpper(hex.EncodeToString(h.hashlock)), h.timeout,
+		strings.ToUpper(hex.EncodeToString(h.preimage)), h.expired, h.refunded,
+	)
+	return
+}
@@ -0,0 +1,80 @@
+package tokenchain_test
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTLC(t *testing.T) {
+	var (
+		chain    = newChain(t)
+		token    = genesis(t, chain, getAccount(0))
+		amount   = big.NewInt(1000)
+		preimage = []byte("0123456789abcdef0123456789abcdef")
+		hashlock = sha256.Sum256(preimage)
+	)
+	_, err := tokenchain.LockHTLC(chain, getAccount(0), getAccount(1).Address(), token, amount, hashlock[:], 0)
+	assert.NotNil(t, err)
+	htlc, err := tokenchain.LockHTLC(chain, getAccount(0), getAccount(1).Address(), token, amount, hashlock[:], 1<<30)
+	require.Nil(t, err)
+	assert.True(t, htlc.Active())
+	assert.Equal(t, getAccount(0).Address(), htlc.Sender())
+	assert.Equal(t, getAccount(1).Address(), htlc.Recipient())
+	assert.Equal(t, amount, htlc.Amount())
+	assert.Equal(t, hashlock[:], htlc.Hashlock())
+	assert.Nil(t, htlc.Preimage())
+	assert.Contains(t, chain.HTLCs(), string(htlc.Hash()))
+	assert.Equal(t, new(big.Int).Sub(supply, amount), token.Balance(getAccount(0).Address()))
+	_, err = htlc.Refund(getAccount(0))
+	assert.NotNil(t, err)
+	_, err = htlc.Claim(getAccount(0), preimage)
+	assert.NotNil(t, err)
+	_, err = htlc.Claim(getAccount(1), []byte("fedcba9876543210fedcba9876543210"))
+	assert.NotNil(t, err)
+	_, err = htlc.Claim(getAccount(1), preimage)
+	require.Nil(t, err)
+	assert.False(t, htlc.Active())
+	assert.Equal(t, preimage, htlc.Preimage())
+	assert.Equal(t, amount, token.Balance(getAccount(1).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	chain2 := restoreChain(t, chain)
+	htlc2, err := chain2.HTLC(htlc.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, preimage, htlc2.Preimage())
+}
+
+func TestHTLCRefund(t *testing.T) {
+	var (
+		chain    = newChain(t)
+		token    = genesis(t, chain, getAccount(0))
+		amount   = big.NewInt(1000)
+		preimage = []byte("0123456789abcdef0123456789abcdef")
+		hashlock = sha256.Sum256(preimage)
+	)
+	height, err := chain.BlockHeight(token.Hash())
+	require.Nil(t, err)
+	htlc, err := tokenchain.LockHTLC(chain, getAccount(0), getAccount(1).Address(), token, amount, hashlock[:], height+3)
+	require.Nil(t, err)
+	assertEqualChain(t, chain, restoreChain(t, chain))
+	_, err = htlc.Refund(getAccount(0))
+	assert.NotNil(t, err)
+	_, err = token.Transfer(getAccount(0), getAccount(1).Address(), amount)
+	require.Nil(t, err)
+	_, err = htlc.Claim(getAccount(1), preimage)
+	assert.NotNil(t, err)
+	_, err = htlc.Refund(getAccount(1))
+	assert.NotNil(t, err)
+	_, err = htlc.Refund(getAccount(0))
+	require.Nil(t, err)
+	assert.False(t, htlc.Active())
+	assert.True(t, htlc.Expired())
+	assert.Nil(t, htlc.Preimage())
+	assert.Equal(t, new(big.Int).Sub(supply, amount), token.Balance(getAccount(0).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
@@ -0,0 +1,173 @@
+package tokenchain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+const shortMemoLen = 8
+
+// TransferRecord represents a transfer in a token's history.
+type TransferRecord struct {
+	Hash     rpc.BlockHash
+	From, To string
+	Amount   *big.Int
+	Memo     string
+}
+
+func (t *Token) transfer(hash rpc.BlockHash, from, to string, amount *big.Int, memo string) {
+	balance := t.Balance(from)
+	t.setBalance(from, balance.Sub(balance, amount))
+	balance = t.Balance(to)
+	t.setBalance(to, balance.Add(balance, amount))
+	t.transfers = append(t.transfers, TransferRecord{
+		Hash:   hash,
+		From:   from,
+		To:     to,
+		Amount: amount,
+		Memo:   memo,
+	})
+	t.c.emit(&Transferred{
+		EventBlock: EventBlock{Hash: hash, Height: t.c.height},
+		Token:      t,
+		From:       from,
+		To:         to,
+		Amount:     new(big.Int).Set(amount),
+		Memo:       memo,
+	})
+}
+
+// Transfers gets the history of transfers to or from account.
+func (t *Token) Transfers(account string) (transfers []TransferRecord) {
+	for _, tr := range t.transfers {
+		if tr.From == account || tr.To == account {
+			tr.Amount = new(big.Int).Set(tr.Amount)
+			transfers = append(transfers, tr)
+		}
+	}
+	return
+}
+
+// TransferWithMemo transfers an amount of tokens to another account with a
+// memo, such as a deposit reference. Memos of up to 8 bytes fit in the
+// transfer message itself, longer ones are sent in continuation blocks.
+func (t *Token) TransferWithMemo(a *wallet.Account, account string, amount *big.Int, memo string) (hash rpc.BlockHash, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if err = t.checkBalance(a.Address(), amount); err != nil {
+		return
+	}
+	if err = t.checkRestrictions(a.Address(), account); err != nil {
+		return
+	}
+	if memo == "" {
+		return nil, errors.New("Memo is empty")
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	if len(memo) <= shortMemoLen && !strings.ContainsRune(memo, 0) {
+		return t.c.send(a, []string{account}, &transferMessage{
+			token:  height,
+			amount: amount,
+			memo:   memo,
+		})
+	}
+	return t.c.send(a, []string{account}, &memoTransferMessage{
+		token:  height,
+		amount: amount,
+		memo:   memo,
+	})
+}
+
+func (m *memoTransferMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if t.checkBalance(info.BlockAccount, m.amount) != nil {
+		return
+	}
+	destination, valid, err := c.getDestination(ctx, info.Contents)
+	if !valid {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 1)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil || t.checkRestrictions(info.BlockAccount, destination) != nil {
+		return false, nil
+	}
+	t.transfer(hash, info.BlockAccount, destination, m.amount, m.memo)
+	return
+}
+
+func (t *Token) loadTransfers(db *sql.DB, hash string) (err error) {
+	t.transfers = nil
+	rows, err := db.Query(`
+		SELECT block, sender, recipient, amount, memo
+		FROM token_transfers WHERE hash = ? ORDER BY seq
+	`, hash)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			tr               TransferRecord
+			block, amountStr string
+			ok               bool
+		)
+		if err = rows.Scan(&block, &tr.From, &tr.To, &amountStr, &tr.Memo); err != nil {
+			return
+		}
+		if tr.Hash, err = hex.DecodeString(block); err != nil {
+			return
+		}
+		if tr.Amount, ok = new(big.Int).SetString(amountStr, 10); !ok {
+			return errors.New("Failed to parse amount from DB")
+		}
+		t.transfers = append(t.transfers, tr)
+	}
+	return rows.Err()
+}
+
+// saveTransfers saves the transfer records not yet in the database. The
+// history only grows, so the records already saved are skipped.
+func (t *Token) saveTransfers(tx *sql.Tx, hash string) (err error) {
+	var saved int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM token_transfers WHERE hash = ?", hash).Scan(&saved); err != nil {
+		return
+	}
+	if saved >= len(t.transfers) {
+		return
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO token_transfers (hash, seq, block, sender, recipient, amount, memo)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	for i := saved; i < len(t.transfers); i++ {
+		tr := t.transfers[i]
+		if _, err = stmt.Exec(
+			hash, i, strings.ToUpper(hex.EncodeToString(tr.Hash)),
+			tr.From, tr.To, tr.Amount.String(), tr.Memo,
+		); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package tokenchain_test
+
+import (
+	"database/sql"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferWithMemo(t *testing.T) {
+	var (
+		chain     = newChain(t)
+		token     = genesis(t, chain, getAccount(0))
+		amount    = big.NewInt(1000)
+		longMemo  = "Invoice 2021-0042: " + strings.Repeat("consulting ", 10)
+		recipient = getAccount(1).Address()
+	)
+	_, err := token.TransferWithMemo(getAccount(0), recipient, amount, "")
+	assert.NotNil(t, err)
+	hash1, err := token.TransferWithMemo(getAccount(0), recipient, amount, "INV42")
+	require.Nil(t, err)
+	hash2, err := token.TransferWithMemo(getAccount(0), recipient, amount, longMemo)
+	require.Nil(t, err)
+	hash3, err := token.Transfer(getAccount(1), payee(t, 0), amount)
+	require.Nil(t, err)
+	assert.Equal(t, amount, token.Balance(recipient))
+	transfers := token.Transfers(recipient)
+	require.Len(t, transfers, 3)
+	assert.Equal(t, hash1, transfers[0].Hash)
+	assert.Equal(t, "INV42", transfers[0].Memo)
+	assert.Equal(t, hash2, transfers[1].Hash)
+	assert.Equal(t, longMemo, transfers[1].Memo)
+	assert.Equal(t, getAccount(0).Address(), transfers[1].From)
+	assert.Equal(t, recipient, transfers[1].To)
+	assert.Equal(t, amount, transfers[1].Amount)
+	assert.Equal(t, hash3, transfers[2].Hash)
+	assert.Equal(t, "", transfers[2].Memo)
+	assert.Len(t, token.Transfers(payee(t, 0)), 1)
+	c := loadChain(t, chain.Address())
+	assertEqualChain(t, chain, c)
+	token2, err := c.Token(token.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, transfers, token2.Transfers(recipient))
+	c = restoreChain(t, chain)
+	token2, err = c.Token(token.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, transfers, token2.Transfers(recipient))
+}
+
+func TestTransferHistorySave(t *testing.T) {
+	var (
+		chain     = newChain(t)
+		token     = genesis(t, chain, getAccount(0))
+		amount    = big.NewInt(1000)
+		recipient = getAccount(1).Address()
+	)
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "chains.db"))
+	require.Nil(t, err)
+	defer db.Close()
+	_, err = token.TransferWithMemo(getAccount(0), recipient, amount, "first")
+	require.Nil(t, err)
+	require.Nil(t, chain.SaveState(db))
+	_, err = token.TransferWithMemo(getAccount(0), recipient, amount, "second")
+	require.Nil(t, err)
+	require.Nil(t, chain.SaveState(db))
+	require.Nil(t, chain.SaveState(db))
+	var count int
+	require.Nil(t, db.QueryRow("SELECT COUNT(*) FROM token_transfers").Scan(&count))
+	assert.Equal(t, 2, count)
+	chain2, err := tokenchain.LoadChain(chain.Address(), rpcURL)
+	require.Nil(t, err)
+	require.Nil(t, chain2.LoadState(db))
+	token2, err := chain2.Token(token.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, token.Transfers(recipient), token2.Transfers(recipient))
+}
@@ -2,27 +2,73 @@ package tokenchain
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
+	"io"
 	"math/big"
 	"strings"
 
 	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/util"
 )
 
 type message interface {
 	serialize() []byte
 	deserialize([]byte)
-	process(*Chain, rpc.BlockHash, uint32, rpc.BlockInfo) (bool, error)
+	process(context.Context, *Chain, rpc.BlockHash, uint32, rpc.BlockInfo) (bool, error)
+}
+
+// extendedMessage is a message carrying extension data in continuation
+// blocks sent ahead of it.
+type extendedMessage interface {
+	message
+	extension() ([]byte, error)
+	setExtension([]byte) error
 }
 
 const (
-	genesisOp     = 1
-	transferOp    = 2
-	swapProposeOp = 3
-	swapAcceptOp  = 4
-	swapConfirmOp = 5
-	swapCancelOp  = 6
+	continuationOp = 0
+	genesisOp      = 1
+	transferOp     = 2
+	swapProposeOp  = 3
+	swapAcceptOp   = 4
+	swapConfirmOp  = 5
+	swapCancelOp   = 6
+	mintOp         = 7
+	burnOp         = 8
+	approveOp      = 9
+	transferFromOp = 10
+	metadataOp     = 11
+	offerOp        = 12
+	fillOp         = 13
+	partialFillOp  = 14
+	nanoSwapOp     = 15
+	nanoPayOp      = 16
+	htlcLockOp     = 17
+	htlcClaimOp    = 18
+	htlcRefundOp   = 19
+	vestingOp      = 20
+	transferManyOp = 21
+	memoTransferOp = 22
+	collectionOp   = 23
+	itemMintOp     = 24
+	itemTransferOp = 25
+	multiGenesisOp = 26
+	batchOp        = 27
+	adminOp        = 28
+	ownerOp        = 29
+	updateOp       = 30
+	flagGenesisOp  = 31
+)
+
+// Flags of a flagged genesis message, carried in the high bits of its
+// decimals byte. Plain genesis messages use the whole byte for decimals.
+const (
+	genesisMintable = 1 << 7
+	genesisAdmin    = 1 << 6
+	genesisFlags    = genesisMintable | genesisAdmin
 )
 
 func newMessageBuffer(op byte) (buf *bytes.Buffer) {
@@ -39,6 +85,8 @@ func parseMessage(data []byte) (m message, err error) {
 	switch data[3] {
 	case genesisOp:
 		m = new(genesisMessage)
+	case flagGenesisOp:
+		m = &genesisMessage{flagged: true}
 	case transferOp:
 		m = new(transferMessage)
 	case swapProposeOp:
@@ -49,13 +97,115 @@ func parseMessage(data []byte) (m message, err error) {
 		m = new(swapConfirmMessage)
 	case swapCancelOp:
 		m = new(swapCancelMessage)
+	case mintOp:
+		m = new(mintMessage)
+	case burnOp:
+		m = new(burnMessage)
+	case approveOp:
+		m = new(approveMessage)
+	case transferFromOp:
+		m = new(transferFromMessage)
+	case metadataOp:
+		m = new(metadataMessage)
+	case offerOp:
+		m = new(offerMessage)
+	case fillOp:
+		m = new(fillMessage)
+	case partialFillOp:
+		m = new(partialFillMessage)
+	case nanoSwapOp:
+		m = new(nanoSwapMessage)
+	case nanoPayOp:
+		m = new(nanoPayMessage)
+	case htlcLockOp:
+		m = new(htlcLockMessage)
+	case htlcClaimOp:
+		m = new(htlcClaimMessage)
+	case htlcRefundOp:
+		m = new(htlcRefundMessage)
+	case vestingOp:
+		m = new(vestingTransferMessage)
+	case transferManyOp:
+		m = new(transferManyMessage)
+	case memoTransferOp:
+		m = new(memoTransferMessage)
+	case collectionOp:
+		m = new(collectionMessage)
+	case itemMintOp:
+		m = new(itemMintMessage)
+	case itemTransferOp:
+		m = new(itemTransferMessage)
+	case multiGenesisOp:
+		m = new(multiGenesisMessage)
+	case batchOp:
+		m = new(batchTransferMessage)
+	case adminOp:
+		m = new(adminMessage)
+	case ownerOp:
+		m = new(ownerMessage)
+	case updateOp:
+		m = new(metadataUpdateMessage)
 	default:
-		return nil, errors.New("Unrecognized op")
+		var ok bool
+		if m, ok = newCustomMessage(data[3]); !ok {
+			return nil, errors.New("Unrecognized op")
+		}
 	}
 	m.deserialize(data[4:])
 	return
 }
 
+const (
+	continuationFirst = 1 << 7
+	continuationLen   = 32 - 5
+	maxExtensionLen   = continuationFirst * continuationLen
+)
+
+// splitExtension splits extension data into continuation blocks in the
+// order they are to be sent. Each block holds the preamble, a sequence
+// number counting down to zero at the block preceding the message, and
+// a flag marking the first block.
+func splitExtension(data []byte) (chunks [][]byte, err error) {
+	if len(data) == 0 || len(data) > maxExtensionLen {
+		return nil, errors.New("Extension length out of range")
+	}
+	n := (len(data) + continuationLen - 1) / continuationLen
+	for i := 0; i < n; i++ {
+		buf := newMessageBuffer(continuationOp)
+		seq := byte(n - 1 - i)
+		if i == 0 {
+			seq |= continuationFirst
+		}
+		buf.WriteByte(seq)
+		chunk := make([]byte, continuationLen)
+		copy(chunk, data[i*continuationLen:])
+		buf.Write(chunk)
+		chunks = append(chunks, buf.Bytes())
+	}
+	return
+}
+
+func writeString(buf *bytes.Buffer, s string) (err error) {
+	if len(s) > 0xffff {
+		return errors.New("String too long")
+	}
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+	return
+}
+
+func readString(r *bytes.Reader) (s string, err error) {
+	var n uint16
+	if err = binary.Read(r, binary.BigEndian, &n); err != nil {
+		return
+	}
+	data := make([]byte, n)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return
+	}
+	return string(data), nil
+}
+
 func writeBigInt(buf *bytes.Buffer, x *big.Int) {
 	if buf.Len() != 16 {
 		panic("buf not at expected len")
@@ -64,14 +214,27 @@ func writeBigInt(buf *bytes.Buffer, x *big.Int) {
 }
 
 type genesisMessage struct {
+	flagged  bool
 	decimals byte
+	mintable bool
+	admin    bool
 	name     string
 	supply   *big.Int
 }
 
 func (m *genesisMessage) serialize() []byte {
-	buf := newMessageBuffer(genesisOp)
-	buf.WriteByte(m.decimals)
+	op, flags := byte(genesisOp), m.decimals
+	if m.mintable || m.admin {
+		op = flagGenesisOp
+	}
+	if m.mintable {
+		flags |= genesisMintable
+	}
+	if m.admin {
+		flags |= genesisAdmin
+	}
+	buf := newMessageBuffer(op)
+	buf.WriteByte(flags)
 	name := make([]byte, 16-buf.Len())
 	copy(name, m.name)
 	buf.Write(name)
@@ -81,6 +244,11 @@ func (m *genesisMessage) serialize() []byte {
 
 func (m *genesisMessage) deserialize(data []byte) {
 	m.decimals = data[0]
+	if m.flagged {
+		m.decimals &^= genesisFlags
+		m.mintable = data[0]&genesisMintable != 0
+		m.admin = data[0]&genesisAdmin != 0
+	}
 	m.name = strings.TrimRight(string(data[1:12]), "\x00")
 	m.supply = new(big.Int).SetBytes(data[12:])
 }
@@ -88,12 +256,15 @@ func (m *genesisMessage) deserialize(data []byte) {
 type transferMessage struct {
 	token  uint32
 	amount *big.Int
+	memo   string
 }
 
 func (m *transferMessage) serialize() []byte {
 	buf := newMessageBuffer(transferOp)
 	binary.Write(buf, binary.BigEndian, m.token)
-	buf.Write(make([]byte, 16-buf.Len()))
+	memo := make([]byte, 16-buf.Len())
+	copy(memo, m.memo)
+	buf.Write(memo)
 	writeBigInt(buf, m.amount)
 	return buf.Bytes()
 }
@@ -101,17 +272,20 @@ func (m *transferMessage) serialize() []byte {
 func (m *transferMessage) deserialize(data []byte) {
 	r := bytes.NewReader(data)
 	binary.Read(r, binary.BigEndian, &m.token)
+	m.memo = strings.TrimRight(string(data[4:12]), "\x00")
 	m.amount = new(big.Int).SetBytes(data[12:])
 }
 
 type swapProposeMessage struct {
-	token  uint32
-	amount *big.Int
+	token        uint32
+	expiryHeight uint32
+	amount       *big.Int
 }
 
 func (m *swapProposeMessage) serialize() []byte {
 	buf := newMessageBuffer(swapProposeOp)
 	binary.Write(buf, binary.BigEndian, m.token)
+	binary.Write(buf, binary.BigEndian, m.expiryHeight)
 	buf.Write(make([]byte, 16-buf.Len()))
 	writeBigInt(buf, m.amount)
 	return buf.Bytes()
@@ -120,6 +294,7 @@ func (m *swapProposeMessage) serialize() []byte {
 func (m *swapProposeMessage) deserialize(data []byte) {
 	r := bytes.NewReader(data)
 	binary.Read(r, binary.BigEndian, &m.token)
+	binary.Read(r, binary.BigEndian, &m.expiryHeight)
 	m.amount = new(big.Int).SetBytes(data[12:])
 }
 
@@ -176,3 +351,722 @@ func (m *swapCancelMessage) deserialize(data []byte) {
 	r := bytes.NewReader(data)
 	binary.Read(r, binary.BigEndian, &m.swap)
 }
+
+type mintMessage struct {
+	token  uint32
+	amount *big.Int
+}
+
+func (m *mintMessage) serialize() []byte {
+	buf := newMessageBuffer(mintOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *mintMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+type burnMessage struct {
+	token  uint32
+	amount *big.Int
+}
+
+func (m *burnMessage) serialize() []byte {
+	buf := newMessageBuffer(burnOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *burnMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+type approveMessage struct {
+	token  uint32
+	amount *big.Int
+}
+
+func (m *approveMessage) serialize() []byte {
+	buf := newMessageBuffer(approveOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *approveMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+type transferFromMessage struct {
+	token  uint32
+	amount *big.Int
+}
+
+func (m *transferFromMessage) serialize() []byte {
+	buf := newMessageBuffer(transferFromOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *transferFromMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+type metadataMessage struct {
+	token    uint32
+	metadata Metadata
+}
+
+func (m *metadataMessage) serialize() []byte {
+	buf := newMessageBuffer(metadataOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 32-buf.Len()))
+	return buf.Bytes()
+}
+
+func (m *metadataMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+}
+
+func (m *metadataMessage) extension() (data []byte, err error) {
+	buf := new(bytes.Buffer)
+	err = writeMetadata(buf, &m.metadata)
+	return buf.Bytes(), err
+}
+
+func (m *metadataMessage) setExtension(data []byte) (err error) {
+	return readMetadata(bytes.NewReader(data), &m.metadata)
+}
+
+func writeMetadata(buf *bytes.Buffer, md *Metadata) (err error) {
+	for _, s := range []string{
+		md.Name,
+		md.Symbol,
+		md.Description,
+		md.URI,
+	} {
+		if err = writeString(buf, s); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func readMetadata(r *bytes.Reader, md *Metadata) (err error) {
+	for _, s := range []*string{
+		&md.Name,
+		&md.Symbol,
+		&md.Description,
+		&md.URI,
+	} {
+		if *s, err = readString(r); err != nil {
+			return
+		}
+	}
+	return
+}
+
+type offerMessage struct {
+	token, want  uint32
+	expiryHeight uint32
+	amount       *big.Int
+	minAmount    *big.Int
+}
+
+func (m *offerMessage) serialize() []byte {
+	buf := newMessageBuffer(offerOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	binary.Write(buf, binary.BigEndian, m.want)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *offerMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	binary.Read(r, binary.BigEndian, &m.want)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+func (m *offerMessage) extension() (data []byte, err error) {
+	buf := new(bytes.Buffer)
+	buf.Write(m.minAmount.FillBytes(make([]byte, 16)))
+	binary.Write(buf, binary.BigEndian, m.expiryHeight)
+	return buf.Bytes(), nil
+}
+
+func (m *offerMessage) setExtension(data []byte) (err error) {
+	if len(data) < 20 {
+		return errors.New("Extension too short")
+	}
+	m.minAmount = new(big.Int).SetBytes(data[:16])
+	m.expiryHeight = binary.BigEndian.Uint32(data[16:])
+	return
+}
+
+type fillMessage struct {
+	offer  uint32
+	amount *big.Int
+}
+
+func (m *fillMessage) serialize() []byte {
+	buf := newMessageBuffer(fillOp)
+	binary.Write(buf, binary.BigEndian, m.offer)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *fillMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.offer)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+type partialFillMessage struct {
+	offer  uint32
+	amount *big.Int
+}
+
+func (m *partialFillMessage) serialize() []byte {
+	buf := newMessageBuffer(partialFillOp)
+	binary.Write(buf, binary.BigEndian, m.offer)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *partialFillMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.offer)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+type nanoSwapMessage struct {
+	token        uint32
+	expiryHeight uint32
+	amount       *big.Int
+	raw          *big.Int
+}
+
+func (m *nanoSwapMessage) serialize() []byte {
+	buf := newMessageBuffer(nanoSwapOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	binary.Write(buf, binary.BigEndian, m.expiryHeight)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *nanoSwapMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	binary.Read(r, binary.BigEndian, &m.expiryHeight)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+func (m *nanoSwapMessage) extension() (data []byte, err error) {
+	if m.raw.BitLen() > 128 {
+		return nil, errors.New("Amount out of range")
+	}
+	return m.raw.FillBytes(make([]byte, 16)), nil
+}
+
+func (m *nanoSwapMessage) setExtension(data []byte) (err error) {
+	if len(data) < 16 {
+		return errors.New("Extension too short")
+	}
+	m.raw = new(big.Int).SetBytes(data[:16])
+	return
+}
+
+type nanoPayMessage struct {
+	swap uint32
+}
+
+func (m *nanoPayMessage) serialize() []byte {
+	buf := newMessageBuffer(nanoPayOp)
+	binary.Write(buf, binary.BigEndian, m.swap)
+	buf.Write(make([]byte, 32-buf.Len()))
+	return buf.Bytes()
+}
+
+func (m *nanoPayMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.swap)
+}
+
+type htlcLockMessage struct {
+	token    uint32
+	amount   *big.Int
+	hashlock []byte
+	timeout  uint32
+}
+
+func (m *htlcLockMessage) serialize() []byte {
+	buf := newMessageBuffer(htlcLockOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *htlcLockMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+func (m *htlcLockMessage) extension() (data []byte, err error) {
+	if len(m.hashlock) != sha256.Size {
+		return nil, errors.New("Invalid hashlock length")
+	}
+	buf := bytes.NewBuffer(append([]byte(nil), m.hashlock...))
+	binary.Write(buf, binary.BigEndian, m.timeout)
+	return buf.Bytes(), nil
+}
+
+func (m *htlcLockMessage) setExtension(data []byte) (err error) {
+	if len(data) < sha256.Size+4 {
+		return errors.New("Extension too short")
+	}
+	m.hashlock = append([]byte(nil), data[:sha256.Size]...)
+	m.timeout = binary.BigEndian.Uint32(data[sha256.Size:])
+	return
+}
+
+type htlcClaimMessage struct {
+	htlc     uint32
+	preimage []byte
+}
+
+func (m *htlcClaimMessage) serialize() []byte {
+	buf := newMessageBuffer(htlcClaimOp)
+	binary.Write(buf, binary.BigEndian, m.htlc)
+	buf.Write(make([]byte, 32-buf.Len()))
+	return buf.Bytes()
+}
+
+func (m *htlcClaimMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.htlc)
+}
+
+func (m *htlcClaimMessage) extension() (data []byte, err error) {
+	if len(m.preimage) != preimageLen {
+		return nil, errors.New("Invalid preimage length")
+	}
+	return m.preimage, nil
+}
+
+func (m *htlcClaimMessage) setExtension(data []byte) (err error) {
+	if len(data) < preimageLen {
+		return errors.New("Extension too short")
+	}
+	m.preimage = append([]byte(nil), data[:preimageLen]...)
+	return
+}
+
+type htlcRefundMessage struct {
+	htlc uint32
+}
+
+func (m *htlcRefundMessage) serialize() []byte {
+	buf := newMessageBuffer(htlcRefundOp)
+	binary.Write(buf, binary.BigEndian, m.htlc)
+	buf.Write(make([]byte, 32-buf.Len()))
+	return buf.Bytes()
+}
+
+func (m *htlcRefundMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.htlc)
+}
+
+type vestingTransferMessage struct {
+	token   uint32
+	amount  *big.Int
+	vesting Vesting
+}
+
+func (m *vestingTransferMessage) serialize() []byte {
+	buf := newMessageBuffer(vestingOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *vestingTransferMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+func (m *vestingTransferMessage) extension() (data []byte, err error) {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, m.vesting.Start)
+	binary.Write(buf, binary.BigEndian, m.vesting.Cliff)
+	binary.Write(buf, binary.BigEndian, m.vesting.End)
+	return buf.Bytes(), nil
+}
+
+func (m *vestingTransferMessage) setExtension(data []byte) (err error) {
+	if len(data) < 12 {
+		return errors.New("Extension too short")
+	}
+	m.vesting.Start = binary.BigEndian.Uint32(data)
+	m.vesting.Cliff = binary.BigEndian.Uint32(data[4:])
+	m.vesting.End = binary.BigEndian.Uint32(data[8:])
+	return
+}
+
+type transferManyMessage struct {
+	token   uint32
+	amount  *big.Int
+	payouts []Payout
+}
+
+func (m *transferManyMessage) serialize() []byte {
+	buf := newMessageBuffer(transferManyOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *transferManyMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+func (m *transferManyMessage) extension() (data []byte, err error) {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, uint16(len(m.payouts)))
+	for _, p := range m.payouts {
+		pubkey, err := util.AddressToPubkey(p.Account)
+		if err != nil {
+			return nil, err
+		}
+		amount := p.Amount.Bytes()
+		buf.Write(pubkey)
+		buf.WriteByte(byte(len(amount)))
+		buf.Write(amount)
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *transferManyMessage) setExtension(data []byte) (err error) {
+	r := bytes.NewReader(data)
+	var n uint16
+	if err = binary.Read(r, binary.BigEndian, &n); err != nil {
+		return
+	}
+	m.payouts = make([]Payout, n)
+	for i := range m.payouts {
+		p := make([]byte, 32+1)
+		if _, err = io.ReadFull(r, p); err != nil {
+			return
+		}
+		if p[32] == 0 || p[32] > 16 {
+			return errors.New("Amount length out of range")
+		}
+		amount := make([]byte, p[32])
+		if _, err = io.ReadFull(r, amount); err != nil {
+			return
+		}
+		if m.payouts[i].Account, err = util.PubkeyToAddress(p[:32]); err != nil {
+			return
+		}
+		m.payouts[i].Amount = new(big.Int).SetBytes(amount)
+	}
+	return
+}
+
+type memoTransferMessage struct {
+	token  uint32
+	amount *big.Int
+	memo   string
+}
+
+func (m *memoTransferMessage) serialize() []byte {
+	buf := newMessageBuffer(memoTransferOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 16-buf.Len()))
+	writeBigInt(buf, m.amount)
+	return buf.Bytes()
+}
+
+func (m *memoTransferMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+func (m *memoTransferMessage) extension() (data []byte, err error) {
+	buf := new(bytes.Buffer)
+	err = writeString(buf, m.memo)
+	return buf.Bytes(), err
+}
+
+func (m *memoTransferMessage) setExtension(data []byte) (err error) {
+	m.memo, err = readString(bytes.NewReader(data))
+	return
+}
+
+type collectionMessage struct {
+	name string
+}
+
+func (m *collectionMessage) serialize() []byte {
+	buf := newMessageBuffer(collectionOp)
+	name := make([]byte, 32-buf.Len())
+	copy(name, m.name)
+	buf.Write(name)
+	return buf.Bytes()
+}
+
+func (m *collectionMessage) deserialize(data []byte) {
+	m.name = strings.TrimRight(string(data), "\x00")
+}
+
+type itemMintMessage struct {
+	collection  uint32
+	id          uint64
+	contentHash []byte
+}
+
+func (m *itemMintMessage) serialize() []byte {
+	buf := newMessageBuffer(itemMintOp)
+	binary.Write(buf, binary.BigEndian, m.collection)
+	binary.Write(buf, binary.BigEndian, m.id)
+	buf.Write(make([]byte, 32-buf.Len()))
+	return buf.Bytes()
+}
+
+func (m *itemMintMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.collection)
+	binary.Read(r, binary.BigEndian, &m.id)
+}
+
+func (m *itemMintMessage) extension() ([]byte, error) {
+	if len(m.contentHash) != contentHashLen {
+		return nil, errors.New("Invalid content hash length")
+	}
+	return m.contentHash, nil
+}
+
+func (m *itemMintMessage) setExtension(data []byte) (err error) {
+	if len(data) < contentHashLen {
+		return errors.New("Extension too short")
+	}
+	m.contentHash = append([]byte(nil), data[:contentHashLen]...)
+	return
+}
+
+type itemTransferMessage struct {
+	collection uint32
+	id         uint64
+}
+
+func (m *itemTransferMessage) serialize() []byte {
+	buf := newMessageBuffer(itemTransferOp)
+	binary.Write(buf, binary.BigEndian, m.collection)
+	binary.Write(buf, binary.BigEndian, m.id)
+	buf.Write(make([]byte, 32-buf.Len()))
+	return buf.Bytes()
+}
+
+func (m *itemTransferMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.collection)
+	binary.Read(r, binary.BigEndian, &m.id)
+}
+
+// writeClassAmounts writes amounts keyed by class ID in ascending order.
+func writeClassAmounts(buf *bytes.Buffer, amounts map[uint32]*big.Int) (err error) {
+	if len(amounts) == 0 || len(amounts) > maxClasses {
+		return errors.New("Number of classes out of range")
+	}
+	binary.Write(buf, binary.BigEndian, uint16(len(amounts)))
+	for _, id := range sortedClasses(amounts) {
+		if amounts[id].Sign() < 0 || amounts[id].BitLen() > 128 {
+			return errors.New("Amount out of range")
+		}
+		binary.Write(buf, binary.BigEndian, id)
+		buf.Write(amounts[id].FillBytes(make([]byte, 16)))
+	}
+	return
+}
+
+func readClassAmounts(r *bytes.Reader) (amounts map[uint32]*big.Int, err error) {
+	var n uint16
+	if err = binary.Read(r, binary.BigEndian, &n); err != nil {
+		return
+	}
+	amounts = make(map[uint32]*big.Int)
+	for i := 0; i < int(n); i++ {
+		var id uint32
+		if err = binary.Read(r, binary.BigEndian, &id); err != nil {
+			return
+		}
+		amount := make([]byte, 16)
+		if _, err = io.ReadFull(r, amount); err != nil {
+			return
+		}
+		if _, ok := amounts[id]; ok {
+			return nil, errors.New("Duplicate class")
+		}
+		amounts[id] = new(big.Int).SetBytes(amount)
+	}
+	return
+}
+
+type multiGenesisMessage struct {
+	name     string
+	supplies map[uint32]*big.Int
+}
+
+func (m *multiGenesisMessage) serialize() []byte {
+	buf := newMessageBuffer(multiGenesisOp)
+	name := make([]byte, 32-buf.Len())
+	copy(name, m.name)
+	buf.Write(name)
+	return buf.Bytes()
+}
+
+func (m *multiGenesisMessage) deserialize(data []byte) {
+	m.name = strings.TrimRight(string(data), "\x00")
+}
+
+func (m *multiGenesisMessage) extension() (data []byte, err error) {
+	buf := new(bytes.Buffer)
+	err = writeClassAmounts(buf, m.supplies)
+	return buf.Bytes(), err
+}
+
+func (m *multiGenesisMessage) setExtension(data []byte) (err error) {
+	m.supplies, err = readClassAmounts(bytes.NewReader(data))
+	return
+}
+
+type batchTransferMessage struct {
+	token   uint32
+	amounts map[uint32]*big.Int
+}
+
+func (m *batchTransferMessage) serialize() []byte {
+	buf := newMessageBuffer(batchOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 32-buf.Len()))
+	return buf.Bytes()
+}
+
+func (m *batchTransferMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+}
+
+func (m *batchTransferMessage) extension() (data []byte, err error) {
+	buf := new(bytes.Buffer)
+	err = writeClassAmounts(buf, m.amounts)
+	return buf.Bytes(), err
+}
+
+func (m *batchTransferMessage) setExtension(data []byte) (err error) {
+	m.amounts, err = readClassAmounts(bytes.NewReader(data))
+	return
+}
+
+type adminMessage struct {
+	token  uint32
+	action byte
+}
+
+func (m *adminMessage) serialize() []byte {
+	buf := newMessageBuffer(adminOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.WriteByte(m.action)
+	buf.Write(make([]byte, 32-buf.Len()))
+	return buf.Bytes()
+}
+
+func (m *adminMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	m.action = data[4]
+}
+
+type ownerMessage struct {
+	token  uint32
+	action byte
+}
+
+func (m *ownerMessage) serialize() []byte {
+	buf := newMessageBuffer(ownerOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.WriteByte(m.action)
+	buf.Write(make([]byte, 32-buf.Len()))
+	return buf.Bytes()
+}
+
+func (m *ownerMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+	m.action = data[4]
+}
+
+type metadataUpdateMessage struct {
+	token    uint32
+	name     string
+	metadata Metadata
+}
+
+func (m *metadataUpdateMessage) serialize() []byte {
+	buf := newMessageBuffer(updateOp)
+	binary.Write(buf, binary.BigEndian, m.token)
+	buf.Write(make([]byte, 32-buf.Len()))
+	return buf.Bytes()
+}
+
+func (m *metadataUpdateMessage) deserialize(data []byte) {
+	r := bytes.NewReader(data)
+	binary.Read(r, binary.BigEndian, &m.token)
+}
+
+func (m *metadataUpdateMessage) extension() (data []byte, err error) {
+	buf := new(bytes.Buffer)
+	if err = writeString(buf, m.name); err != nil {
+		return
+	}
+	err = writeMetadata(buf, &m.metadata)
+	return buf.Bytes(), err
+}
+
+func (m *metadataUpdateMessage) setExtension(data []byte) (err error) {
+	r := bytes.NewReader(data)
+	if m.name, err = readString(r); err != nil {
+		return
+	}
+	return readMetadata(r, &m.metadata)
+}
@@ -0,0 +1,207 @@
+package tokenchain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+// Metadata represents extended token metadata.
+type Metadata struct {
+	Name        string
+	Symbol      string
+	Description string
+	URI         string
+}
+
+// FullName returns the full token name, falling back to the genesis name.
+func (t *Token) FullName() string {
+	if t.metadata == nil {
+		return t.name
+	}
+	return t.metadata.Name
+}
+
+// Symbol returns the token ticker symbol.
+func (t *Token) Symbol() string {
+	if t.metadata == nil {
+		return ""
+	}
+	return t.metadata.Symbol
+}
+
+// Description returns the token description.
+func (t *Token) Description() string {
+	if t.metadata == nil {
+		return ""
+	}
+	return t.metadata.Description
+}
+
+// URI returns the token URI.
+func (t *Token) URI() string {
+	if t.metadata == nil {
+		return ""
+	}
+	return t.metadata.URI
+}
+
+// SetMetadata sets the extended metadata of the token.
+func (t *Token) SetMetadata(a *wallet.Account, md Metadata) (hash rpc.BlockHash, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if err = t.checkSetMetadata(a.Address()); err != nil {
+		return
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	return t.c.send(a, nil, &metadataMessage{
+		token:    height,
+		metadata: md,
+	})
+}
+
+func (t *Token) checkSetMetadata(account string) (err error) {
+	if account != t.owner {
+		return errors.New("Must set metadata with owner account")
+	}
+	if t.metadata != nil {
+		err = errors.New("Metadata already set")
+	}
+	return
+}
+
+func (m *metadataMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if t.checkSetMetadata(info.BlockAccount) != nil {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 0)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil {
+		return false, nil
+	}
+	t.metadata = &m.metadata
+	return
+}
+
+// MetadataRecord represents the name and extended metadata of a token as
+// they were before an update.
+type MetadataRecord struct {
+	// Hash is the block hash of the update that replaced them.
+	Hash     rpc.BlockHash
+	Name     string
+	Metadata Metadata
+}
+
+// MetadataHistory gets the earlier names and extended metadata of the
+// token, oldest first.
+func (t *Token) MetadataHistory() []MetadataRecord {
+	return append([]MetadataRecord(nil), t.metadataHistory...)
+}
+
+// UpdateMetadata replaces the name and extended metadata of the token,
+// keeping the earlier values in its history. An empty name leaves the
+// name unchanged.
+func (t *Token) UpdateMetadata(a *wallet.Account, name string, md Metadata) (hash rpc.BlockHash, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if a.Address() != t.owner {
+		return nil, errors.New("Must update metadata with owner account")
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	return t.c.send(a, nil, &metadataUpdateMessage{
+		token:    height,
+		name:     name,
+		metadata: md,
+	})
+}
+
+func (m *metadataUpdateMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok || info.BlockAccount != t.owner {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 0)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil {
+		return false, nil
+	}
+	record := MetadataRecord{Hash: hash, Name: t.name}
+	if t.metadata != nil {
+		record.Metadata = *t.metadata
+	}
+	t.metadataHistory = append(t.metadataHistory, record)
+	if m.name != "" {
+		t.name = m.name
+	}
+	t.metadata = &m.metadata
+	return
+}
+
+func (t *Token) loadMetadataHistory(db *sql.DB, hash string) (err error) {
+	t.metadataHistory = nil
+	rows, err := db.Query(`
+		SELECT block, token_name, name, symbol, description, uri
+		FROM token_metadata_history WHERE hash = ? ORDER BY seq
+	`, hash)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			r     MetadataRecord
+			block string
+		)
+		if err = rows.Scan(
+			&block, &r.Name, &r.Metadata.Name, &r.Metadata.Symbol, &r.Metadata.Description, &r.Metadata.URI,
+		); err != nil {
+			return
+		}
+		if r.Hash, err = hex.DecodeString(block); err != nil {
+			return
+		}
+		t.metadataHistory = append(t.metadataHistory, r)
+	}
+	return rows.Err()
+}
+
+func (t *Token) saveMetadataHistory(tx *sql.Tx, hash string) (err error) {
+	stmt, err := tx.Prepare(`
+		REPLACE INTO token_metadata_history (hash, seq, block, token_name, name, symbol, description, uri)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	for i, r := range t.metadataHistory {
+		if _, err = stmt.Exec(
+			hash, i, strings.ToUpper(hex.EncodeToString(r.Hash)), r.Name,
+			r.Metadata.Name, r.Metadata.Symbol, r.Metadata.Description, r.Metadata.URI,
+		); err != nil {
+			return
+		}
+	}
+	return
+}
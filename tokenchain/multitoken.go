@@ -0,0 +1,302 @@
+package tokenchain
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+const maxClasses = (maxExtensionLen - 2) / (4 + 16)
+
+// MultiToken represents a set of token classes under one issuer, each
+// identified by a class ID and with its own supply. Amounts of several
+// classes can be transferred together in one message.
+type MultiToken struct {
+	c        *Chain
+	hash     rpc.BlockHash
+	issuer   string
+	name     string
+	supplies map[uint32]*big.Int
+	balances map[uint32]map[string]*big.Int
+}
+
+func sortedClasses(amounts map[uint32]*big.Int) (ids []uint32) {
+	for id := range amounts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return
+}
+
+// Hash returns the block hash of the multi-token.
+func (mt *MultiToken) Hash() rpc.BlockHash {
+	return mt.hash
+}
+
+// Issuer returns the account that issued the multi-token.
+func (mt *MultiToken) Issuer() string {
+	return mt.issuer
+}
+
+// Name returns the multi-token name.
+func (mt *MultiToken) Name() string {
+	return mt.name
+}
+
+// Classes returns the class IDs in ascending order.
+func (mt *MultiToken) Classes() []uint32 {
+	return sortedClasses(mt.supplies)
+}
+
+// Supply returns the supply of a class.
+func (mt *MultiToken) Supply(id uint32) *big.Int {
+	supply, ok := mt.supplies[id]
+	if !ok {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(supply)
+}
+
+// Balance gets the balance of a class for account.
+func (mt *MultiToken) Balance(id uint32, account string) *big.Int {
+	balance, ok := mt.balances[id][account]
+	if !ok {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(balance)
+}
+
+// Balances gets the balances of all classes held by account.
+func (mt *MultiToken) Balances(account string) (balances map[uint32]*big.Int) {
+	balances = make(map[uint32]*big.Int)
+	for id, b := range mt.balances {
+		if balance, ok := b[account]; ok && balance.Sign() > 0 {
+			balances[id] = new(big.Int).Set(balance)
+		}
+	}
+	return
+}
+
+func (mt *MultiToken) setBalance(id uint32, account string, balance *big.Int) {
+	if mt.balances[id] == nil {
+		mt.balances[id] = make(map[string]*big.Int)
+	}
+	mt.balances[id][account] = balance
+}
+
+// MultiTokenGenesis initializes a new multi-token on a chain with the
+// supplies of its classes keyed by class ID.
+func MultiTokenGenesis(c *Chain, a *wallet.Account, name string, supplies map[uint32]*big.Int) (mt *MultiToken, err error) {
+	if err = c.Parse(); err != nil {
+		return
+	}
+	for _, supply := range supplies {
+		if err = checkPositive(supply); err != nil {
+			return
+		}
+	}
+	hash, err := c.send(a, nil, &multiGenesisMessage{
+		name:     name,
+		supplies: supplies,
+	})
+	if err != nil {
+		return
+	}
+	return c.MultiToken(hash)
+}
+
+func (m *multiGenesisMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	data, valid, err := c.getExtension(ctx, info.Contents, 0)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil || len(m.supplies) == 0 {
+		return false, nil
+	}
+	mt := &MultiToken{
+		c:        c,
+		hash:     hash,
+		issuer:   info.BlockAccount,
+		name:     m.name,
+		supplies: m.supplies,
+		balances: make(map[uint32]map[string]*big.Int),
+	}
+	for id, supply := range m.supplies {
+		mt.setBalance(id, info.BlockAccount, new(big.Int).Set(supply))
+	}
+	c.multiTokens[height] = mt
+	return
+}
+
+// TransferBatch transfers amounts of several classes, keyed by class ID,
+// to another account in one message. Either all or none are transferred.
+func (mt *MultiToken) TransferBatch(a *wallet.Account, account string, amounts map[uint32]*big.Int) (hash rpc.BlockHash, err error) {
+	if err = mt.c.Parse(); err != nil {
+		return
+	}
+	if err = mt.checkTransfer(a.Address(), amounts); err != nil {
+		return
+	}
+	height, err := mt.c.getHeight(mt.hash)
+	if err != nil {
+		return
+	}
+	return mt.c.send(a, []string{account}, &batchTransferMessage{
+		token:   height,
+		amounts: amounts,
+	})
+}
+
+func (mt *MultiToken) checkTransfer(account string, amounts map[uint32]*big.Int) (err error) {
+	if len(amounts) == 0 {
+		return errors.New("No classes to transfer")
+	}
+	for id, amount := range amounts {
+		if _, ok := mt.supplies[id]; !ok {
+			return errors.New("Class not found")
+		}
+		if err = checkPositive(amount); err != nil {
+			return
+		}
+		if mt.Balance(id, account).Cmp(amount) < 0 {
+			return errors.New("Insufficient balance")
+		}
+	}
+	return
+}
+
+func (m *batchTransferMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	mt, ok := c.multiTokens[m.token]
+	if !ok {
+		return
+	}
+	destination, valid, err := c.getDestination(ctx, info.Contents)
+	if !valid {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 1)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil || mt.checkTransfer(info.BlockAccount, m.amounts) != nil {
+		return false, nil
+	}
+	for id, amount := range m.amounts {
+		balance := mt.Balance(id, info.BlockAccount)
+		mt.setBalance(id, info.BlockAccount, balance.Sub(balance, amount))
+		balance = mt.Balance(id, destination)
+		mt.setBalance(id, destination, balance.Add(balance, amount))
+	}
+	return
+}
+
+// MultiTokens gets the chain's multi-tokens.
+func (c *Chain) MultiTokens() (multiTokens map[string]*MultiToken) {
+	multiTokens = make(map[string]*MultiToken)
+	for _, mt := range c.multiTokens {
+		multiTokens[string(mt.Hash())] = mt
+	}
+	return
+}
+
+// MultiToken gets the multi-token at the specified block hash.
+func (c *Chain) MultiToken(hash rpc.BlockHash) (mt *MultiToken, err error) {
+	for _, mt = range c.multiTokens {
+		if bytes.Equal(hash, mt.Hash()) {
+			return
+		}
+	}
+	return nil, errors.New("Multi-token not found")
+}
+
+func (mt *MultiToken) loadState(db *sql.DB) (err error) {
+	hash := strings.ToUpper(hex.EncodeToString(mt.hash))
+	row := db.QueryRow("SELECT issuer, name FROM multi_tokens WHERE hash = ?", hash)
+	if err = row.Scan(&mt.issuer, &mt.name); err != nil {
+		return
+	}
+	mt.supplies = make(map[uint32]*big.Int)
+	rows, err := db.Query("SELECT class, supply FROM multi_token_classes WHERE hash = ?", hash)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id        uint32
+			supplyStr string
+			ok        bool
+		)
+		if err = rows.Scan(&id, &supplyStr); err != nil {
+			return
+		}
+		if mt.supplies[id], ok = new(big.Int).SetString(supplyStr, 10); !ok {
+			return errors.New("Failed to parse supply from DB")
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	mt.balances = make(map[uint32]map[string]*big.Int)
+	rows, err = db.Query("SELECT class, account, balance FROM multi_token_balances WHERE hash = ?", hash)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id                  uint32
+			account, balanceStr string
+		)
+		if err = rows.Scan(&id, &account, &balanceStr); err != nil {
+			return
+		}
+		balance, ok := new(big.Int).SetString(balanceStr, 10)
+		if !ok {
+			return errors.New("Failed to parse balance from DB")
+		}
+		mt.setBalance(id, account, balance)
+	}
+	return rows.Err()
+}
+
+func (mt *MultiToken) saveState(tx *sql.Tx, height uint32) (err error) {
+	hash := strings.ToUpper(hex.EncodeToString(mt.hash))
+	if _, err = tx.Exec(
+		"REPLACE INTO multi_tokens (hash, chain, height, issuer, name) VALUES (?, ?, ?, ?, ?)",
+		hash, mt.c.Address(), height, mt.issuer, mt.name,
+	); err != nil {
+		return
+	}
+	stmt, err := tx.Prepare("REPLACE INTO multi_token_classes (hash, class, supply) VALUES (?, ?, ?)")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	for id, supply := range mt.supplies {
+		if _, err = stmt.Exec(hash, id, supply.String()); err != nil {
+			return
+		}
+	}
+	stmt2, err := tx.Prepare("REPLACE INTO multi_token_balances (hash, class, account, balance) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+	defer stmt2.Close()
+	for id, balances := range mt.balances {
+		for account, balance := range balances {
+			if _, err = stmt2.Exec(hash, id, account, balance.String()); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,75 @@
+package tokenchain_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertEqualMultiTokens(t *testing.T, c1, c2 *tokenchain.Chain) {
+	multiTokens1, multiTokens2 := c1.MultiTokens(), c2.MultiTokens()
+	assert.Len(t, multiTokens1, len(multiTokens2))
+	for hash, mt1 := range multiTokens1 {
+		mt2, ok := multiTokens2[hash]
+		require.True(t, ok)
+		assert.Equal(t, mt1.Name(), mt2.Name())
+		assert.Equal(t, mt1.Issuer(), mt2.Issuer())
+		assert.Equal(t, mt1.Classes(), mt2.Classes())
+		for _, id := range mt1.Classes() {
+			assert.Equal(t, mt1.Supply(id), mt2.Supply(id))
+		}
+		for _, account := range []string{getAccount(0).Address(), getAccount(1).Address()} {
+			assert.Equal(t, mt1.Balances(account), mt2.Balances(account))
+		}
+	}
+}
+
+func TestMultiToken(t *testing.T) {
+	var (
+		chain    = newChain(t)
+		issuer   = getAccount(0)
+		player   = getAccount(1)
+		supplies = map[uint32]*big.Int{
+			1: big.NewInt(1),
+			2: big.NewInt(100),
+			3: big.NewInt(1e6),
+		}
+	)
+	mt, err := tokenchain.MultiTokenGenesis(chain, issuer, "GAME", supplies)
+	require.Nil(t, err)
+	assert.Equal(t, "GAME", mt.Name())
+	assert.Equal(t, []uint32{1, 2, 3}, mt.Classes())
+	assert.Equal(t, supplies, mt.Balances(issuer.Address()))
+	_, err = mt.TransferBatch(issuer, player.Address(), map[uint32]*big.Int{4: big.NewInt(1)})
+	assert.NotNil(t, err)
+	_, err = mt.TransferBatch(issuer, player.Address(), map[uint32]*big.Int{
+		1: big.NewInt(1),
+		2: big.NewInt(101),
+	})
+	assert.NotNil(t, err)
+	_, err = mt.TransferBatch(issuer, player.Address(), map[uint32]*big.Int{
+		1: big.NewInt(1),
+		3: big.NewInt(500),
+	})
+	require.Nil(t, err)
+	assert.Equal(t, map[uint32]*big.Int{
+		1: big.NewInt(1),
+		3: big.NewInt(500),
+	}, mt.Balances(player.Address()))
+	assert.Equal(t, map[uint32]*big.Int{
+		2: big.NewInt(100),
+		3: big.NewInt(1e6 - 500),
+	}, mt.Balances(issuer.Address()))
+	_, err = mt.TransferBatch(player, issuer.Address(), map[uint32]*big.Int{
+		1: big.NewInt(1),
+		3: big.NewInt(501),
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, big.NewInt(1), mt.Balance(1, player.Address()))
+	assert.Equal(t, big.NewInt(1), mt.Supply(1))
+	assertEqualMultiTokens(t, chain, loadChain(t, chain.Address()))
+	assertEqualMultiTokens(t, chain, restoreChain(t, chain))
+}
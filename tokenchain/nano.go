@@ -0,0 +1,156 @@
+package tokenchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+// ProposeSwapForNano proposes a swap on-chain of an amount of tokens for an
+// amount of Nano raw paid by the counterparty. The tokens are held in escrow
+// until the counterparty pays or cancels, or the swap expires. The proposer
+// cannot cancel, so an expiry is required.
+func ProposeSwapForNano(c *Chain, a *wallet.Account, counterparty string, t *Token, amount, raw *big.Int, expiry Expiry) (s *Swap, err error) {
+	if err = c.Parse(); err != nil {
+		return
+	}
+	if err = t.checkBalance(a.Address(), amount); err != nil {
+		return
+	}
+	if err = t.checkRestrictions(a.Address()); err != nil {
+		return
+	}
+	if raw.Sign() <= 0 {
+		return nil, errors.New("Amount is not positive")
+	}
+	if expiry.Height == 0 {
+		return nil, errors.New("Expiry is required")
+	}
+	m := &nanoSwapMessage{
+		expiryHeight: expiry.Height,
+		amount:       amount,
+		raw:          raw,
+	}
+	if m.token, err = c.getHeight(t.hash); err != nil {
+		return
+	}
+	hash, err := c.send(a, []string{counterparty}, m)
+	if err != nil {
+		return
+	}
+	return c.Swap(hash)
+}
+
+func (m *nanoSwapMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if t.checkBalance(info.BlockAccount, m.amount) != nil || t.checkRestrictions(info.BlockAccount) != nil {
+		return
+	}
+	if m.expiryHeight == 0 {
+		return
+	}
+	destination, valid, err := c.getDestination(ctx, info.Contents)
+	if !valid {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 1)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil || m.raw.Sign() == 0 {
+		return false, nil
+	}
+	balance := t.Balance(info.BlockAccount)
+	t.setBalance(info.BlockAccount, balance.Sub(balance, m.amount))
+	s := &Swap{
+		c:    c,
+		hash: hash,
+		left: SwapLeg{
+			Account: info.BlockAccount,
+			Token:   t,
+			Amount:  m.amount,
+		},
+		right: SwapLeg{
+			Account: destination,
+			Amount:  m.raw,
+		},
+		remaining: m.amount,
+		expiry:    Expiry{Height: m.expiryHeight},
+		nano:      true,
+	}
+	c.swaps[height] = s
+	c.emit(&SwapProposed{s.event(hash, height, info.BlockAccount)})
+	return
+}
+
+// payMargin is the number of chain blocks before expiry within which Pay
+// refuses to pay, so that the payment is not stranded by the swap expiring
+// before the message releasing the tokens is received.
+const payMargin = 10
+
+// Pay pays for a swap for Nano, sending the raw amount to the proposer
+// ahead of a message that releases the escrowed tokens. The tokens are
+// released whenever the message and payment are received before expiry,
+// even if the token is paused or an account frozen meanwhile. The raw
+// cannot be recovered if the swap expires first, so Pay refuses to pay
+// within 10 chain blocks of expiry.
+func (s *Swap) Pay(a *wallet.Account) (hash rpc.BlockHash, err error) {
+	if err = s.c.Parse(); err != nil {
+		return
+	}
+	if err = s.checkPay(a.Address()); err != nil {
+		return
+	}
+	if s.expiry.expired(s.c.now() + payMargin) {
+		return nil, errors.New("Swap is about to expire")
+	}
+	height, err := s.c.getHeight(s.hash)
+	if err != nil {
+		return
+	}
+	return s.c.sendAmounts(context.Background(), a, []string{s.left.Account}, []*big.Int{s.right.Amount}, &nanoPayMessage{swap: height})
+}
+
+func (s *Swap) checkPay(account string) (err error) {
+	if s.inactive {
+		return errors.New("Swap is inactive")
+	}
+	if !s.nano {
+		return errors.New("Swap is not for Nano")
+	}
+	if account != s.right.Account {
+		return errors.New("Must pay swap with right account")
+	}
+	return
+}
+
+func (m *nanoPayMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	s, ok := c.swaps[m.swap]
+	if !ok {
+		return
+	}
+	if s.checkPay(info.BlockAccount) != nil {
+		return
+	}
+	sends, valid, err := c.getSends(ctx, info.Contents, 1)
+	if !valid {
+		return
+	}
+	payment := sends[0]
+	if payment.Contents.LinkAsAccount != s.left.Account || payment.Amount.Cmp(s.right.Amount) != 0 {
+		return false, nil
+	}
+	balance := s.left.Token.Balance(s.right.Account)
+	s.left.Token.setBalance(s.right.Account, balance.Add(balance, s.remaining))
+	s.remaining = new(big.Int)
+	s.inactive = true
+	delete(c.swaps, m.swap)
+	c.emit(&SwapConfirmed{s.event(hash, height, info.BlockAccount)})
+	return
+}
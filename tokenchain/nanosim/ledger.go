@@ -0,0 +1,354 @@
+// Package nanosim provides an in-memory simulated Nano ledger for offline testing.
+package nanosim
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/util"
+	"github.com/hectorchu/gonano/wallet/ed25519"
+)
+
+type block struct {
+	info rpc.BlockInfo
+	hash rpc.BlockHash
+	next rpc.BlockHash
+}
+
+type account struct {
+	open, frontier rpc.BlockHash
+	modified       time.Time
+}
+
+type pending struct {
+	amount *big.Int
+	source string
+}
+
+// Ledger represents a simulated Nano ledger.
+type Ledger struct {
+	m           sync.Mutex
+	blocks      map[string]*block
+	accounts    map[string]*account
+	pendings    map[string]map[string]pending
+	genesis     ed25519.PrivateKey
+	offset      time.Duration
+	autoConfirm bool
+}
+
+// NewLedger initializes a new ledger holding the maximum supply in a genesis account.
+func NewLedger() (l *Ledger) {
+	l = &Ledger{
+		blocks:      make(map[string]*block),
+		accounts:    make(map[string]*account),
+		pendings:    make(map[string]map[string]pending),
+		genesis:     ed25519.NewKeyFromSeed(make([]byte, 32)),
+		autoConfirm: true,
+	}
+	address, _ := util.PubkeyToAddress(l.genesis.Public().(ed25519.PublicKey))
+	b := &rpc.Block{
+		Type:           "state",
+		Account:        address,
+		Previous:       make(rpc.BlockHash, 32),
+		Representative: address,
+		Balance:        &rpc.RawAmount{Int: *new(big.Int).Lsh(big.NewInt(1), 128)},
+		Link:           make(rpc.BlockHash, 32),
+	}
+	b.Balance.Sub(&b.Balance.Int, big.NewInt(1))
+	l.sign(b)
+	hash, _ := b.Hash()
+	l.insert(hash, b, "open", &b.Balance.Int)
+	return
+}
+
+// GenesisAddress returns the address of the genesis account.
+func (l *Ledger) GenesisAddress() string {
+	address, _ := util.PubkeyToAddress(l.genesis.Public().(ed25519.PublicKey))
+	return address
+}
+
+// Fund sends an amount from the genesis account to an account, leaving it receivable.
+func (l *Ledger) Fund(address string, amount *big.Int) (hash rpc.BlockHash, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	link, err := util.AddressToPubkey(address)
+	if err != nil {
+		return
+	}
+	a := l.accounts[l.GenesisAddress()]
+	prev := l.blocks[a.frontier.String()]
+	balance := new(big.Int).Sub(&prev.info.Balance.Int, amount)
+	if amount.Sign() <= 0 || balance.Sign() < 0 {
+		return nil, errors.New("Invalid amount")
+	}
+	b := &rpc.Block{
+		Type:           "state",
+		Account:        prev.info.BlockAccount,
+		Previous:       prev.hash,
+		Representative: prev.info.Contents.Representative,
+		Balance:        &rpc.RawAmount{Int: *balance},
+		Link:           link,
+	}
+	l.sign(b)
+	return l.process(b, "")
+}
+
+// SetAutoConfirm sets whether newly processed blocks are confirmed immediately.
+func (l *Ledger) SetAutoConfirm(autoConfirm bool) {
+	l.m.Lock()
+	l.autoConfirm = autoConfirm
+	l.m.Unlock()
+}
+
+// Confirm confirms a block together with all its predecessors.
+func (l *Ledger) Confirm(hash rpc.BlockHash) (err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	b, ok := l.blocks[hash.String()]
+	if !ok {
+		return errors.New("Block not found")
+	}
+	for ; b != nil && !b.info.Confirmed; b = l.blocks[b.info.Contents.Previous.String()] {
+		b.info.Confirmed = true
+	}
+	return
+}
+
+// Now returns the ledger's current time.
+func (l *Ledger) Now() time.Time {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.now()
+}
+
+// Advance moves the ledger's clock forward.
+func (l *Ledger) Advance(d time.Duration) {
+	l.m.Lock()
+	l.offset += d
+	l.m.Unlock()
+}
+
+func (l *Ledger) now() time.Time {
+	return time.Now().Add(l.offset).UTC()
+}
+
+func (l *Ledger) sign(b *rpc.Block) {
+	hash, _ := b.Hash()
+	b.Signature = ed25519.Sign(l.genesis, hash)
+}
+
+func (l *Ledger) insert(hash rpc.BlockHash, b *rpc.Block, subtype string, amount *big.Int) {
+	b.LinkAsAccount, _ = util.PubkeyToAddress(b.Link)
+	a, ok := l.accounts[b.Account]
+	if !ok {
+		a = &account{open: hash}
+		l.accounts[b.Account] = a
+	}
+	var height uint64 = 1
+	if prev, ok := l.blocks[b.Previous.String()]; ok {
+		prev.next = hash
+		height = prev.info.Height + 1
+	}
+	a.frontier = hash
+	a.modified = l.now()
+	l.blocks[hash.String()] = &block{
+		hash: hash,
+		info: rpc.BlockInfo{
+			BlockAccount:   b.Account,
+			Amount:         &rpc.RawAmount{Int: *amount},
+			Balance:        b.Balance,
+			Height:         height,
+			LocalTimestamp: uint64(l.now().Unix()),
+			Confirmed:      l.autoConfirm,
+			Contents:       b,
+			Subtype:        subtype,
+		},
+	}
+	switch subtype {
+	case "send":
+		if l.pendings[b.LinkAsAccount] == nil {
+			l.pendings[b.LinkAsAccount] = make(map[string]pending)
+		}
+		l.pendings[b.LinkAsAccount][hash.String()] = pending{amount: amount, source: b.Account}
+	case "receive", "open":
+		if p, ok := l.pendings[b.Account]; ok {
+			delete(p, b.Link.String())
+		}
+	}
+}
+
+func (l *Ledger) process(b *rpc.Block, subtype string) (hash rpc.BlockHash, err error) {
+	if b.Type != "state" || b.Balance == nil || len(b.Previous) != 32 || len(b.Link) != 32 {
+		return nil, errors.New("Invalid block")
+	}
+	if hash, err = b.Hash(); err != nil {
+		return
+	}
+	if _, ok := l.blocks[hash.String()]; ok {
+		return nil, errors.New("Old block")
+	}
+	pubkey, err := util.AddressToPubkey(b.Account)
+	if err != nil {
+		return
+	}
+	if !ed25519.Verify(pubkey, hash, b.Signature) {
+		return nil, errors.New("Bad signature")
+	}
+	if _, err = util.AddressToPubkey(b.Representative); err != nil {
+		return
+	}
+	balance := new(big.Int)
+	if a, ok := l.accounts[b.Account]; ok {
+		if !bytes.Equal(b.Previous, a.frontier) {
+			return nil, errors.New("Fork")
+		}
+		balance = &l.blocks[a.frontier.String()].info.Balance.Int
+	} else if bytes.Count(b.Previous, []byte{0}) != len(b.Previous) {
+		return nil, errors.New("Gap previous block")
+	}
+	amount := new(big.Int).Sub(&b.Balance.Int, balance)
+	var actual string
+	switch amount.Sign() {
+	case -1:
+		actual = "send"
+		amount.Neg(amount)
+	case 0:
+		if bytes.Count(b.Link, []byte{0}) != len(b.Link) {
+			return nil, errors.New("Invalid block balance for given subtype")
+		}
+		actual = "change"
+	case 1:
+		p, ok := l.pendings[b.Account][b.Link.String()]
+		if !ok {
+			if _, ok := l.blocks[b.Link.String()]; ok {
+				return nil, errors.New("Unreceivable")
+			}
+			return nil, errors.New("Gap source block")
+		}
+		if p.amount.Cmp(amount) != 0 {
+			return nil, errors.New("Balance and amount delta do not match")
+		}
+		actual = "receive"
+	}
+	if bytes.Count(b.Previous, []byte{0}) == len(b.Previous) {
+		if actual != "receive" {
+			return nil, errors.New("Invalid block balance for given subtype")
+		}
+		actual = "open"
+	}
+	switch subtype {
+	case "", actual:
+	case "receive":
+		if actual != "open" {
+			return nil, errors.New("Invalid block balance for given subtype")
+		}
+	default:
+		return nil, errors.New("Invalid block balance for given subtype")
+	}
+	l.insert(hash, b, actual, amount)
+	return
+}
+
+func (l *Ledger) accountInfo(address string) (info rpc.AccountInfo, err error) {
+	a, ok := l.accounts[address]
+	if !ok {
+		return info, errors.New("Account not found")
+	}
+	frontier := l.blocks[a.frontier.String()]
+	info = rpc.AccountInfo{
+		Frontier:          a.frontier,
+		OpenBlock:         a.open,
+		Balance:           frontier.info.Balance,
+		ModifiedTimestamp: uint64(a.modified.Unix()),
+		BlockCount:        frontier.info.Height,
+		Representative:    frontier.info.Contents.Representative,
+		Weight:            &rpc.RawAmount{},
+		Pending:           &rpc.RawAmount{Int: *l.receivable(address)},
+	}
+	for b := frontier; b != nil; b = l.blocks[b.info.Contents.Previous.String()] {
+		if b.info.Confirmed {
+			info.ConfirmationHeight = b.info.Height
+			info.ConfirmationHeightFrontier = b.hash
+			break
+		}
+	}
+	for b := frontier; b != nil; b = l.blocks[b.info.Contents.Previous.String()] {
+		prev, ok := l.blocks[b.info.Contents.Previous.String()]
+		if !ok || prev.info.Contents.Representative != b.info.Contents.Representative {
+			info.RepresentativeBlock = b.hash
+			break
+		}
+	}
+	return
+}
+
+func (l *Ledger) accountsPending(accounts []string) (pending map[string]rpc.HashToPendingMap) {
+	pending = make(map[string]rpc.HashToPendingMap)
+	for _, account := range accounts {
+		pending[account] = make(rpc.HashToPendingMap)
+		for hash, p := range l.pendings[account] {
+			if b := l.blocks[hash]; !b.info.Confirmed {
+				continue
+			}
+			pending[account][hash] = rpc.AccountPending{
+				Amount: &rpc.RawAmount{Int: *p.amount},
+				Source: p.source,
+			}
+		}
+	}
+	return
+}
+
+func (l *Ledger) receivable(address string) (amount *big.Int) {
+	amount = new(big.Int)
+	for _, p := range l.pendings[address] {
+		amount.Add(amount, p.amount)
+	}
+	return
+}
+
+func (l *Ledger) blockInfo(hash rpc.BlockHash) (info rpc.BlockInfo, err error) {
+	b, ok := l.blocks[hash.String()]
+	if !ok {
+		return info, errors.New("Block not found")
+	}
+	return b.info, nil
+}
+
+func (l *Ledger) successors(hash rpc.BlockHash, count int64) (hashes []rpc.BlockHash, err error) {
+	b, ok := l.blocks[hash.String()]
+	if !ok {
+		return nil, errors.New("Block not found")
+	}
+	for ; b != nil && count != 0; b, count = l.blocks[b.next.String()], count-1 {
+		hashes = append(hashes, b.hash)
+	}
+	return
+}
+
+func (l *Ledger) ledger(start string, count int64, modifiedSince int64) (accounts map[string]rpc.AccountInfo) {
+	addresses := make([]string, 0, len(l.accounts))
+	for address := range l.accounts {
+		if strings.Compare(address, start) >= 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	accounts = make(map[string]rpc.AccountInfo)
+	for _, address := range addresses {
+		if count >= 0 && int64(len(accounts)) >= count {
+			break
+		}
+		if l.accounts[address].modified.Unix() < modifiedSince {
+			continue
+		}
+		accounts[address], _ = l.accountInfo(address)
+	}
+	return
+}
@@ -0,0 +1,114 @@
+package nanosim_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+	"github.com/hectorchu/nano-token-protocol/tokenchain/nanosim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAccount(t *testing.T, s *nanosim.Server, i byte) (a *wallet.Account) {
+	seed := make([]byte, 32)
+	seed[0] = i
+	w, err := wallet.NewWallet(seed)
+	require.Nil(t, err)
+	w.RPC.URL = s.URL
+	a, err = w.NewAccount(nil)
+	require.Nil(t, err)
+	return
+}
+
+func TestSendReceive(t *testing.T) {
+	s := nanosim.NewServer()
+	defer s.Close()
+	a, b := newAccount(t, s, 1), newAccount(t, s, 2)
+	_, err := s.Fund(a.Address(), big.NewInt(1000))
+	require.Nil(t, err)
+	require.Nil(t, a.ReceivePendings())
+	hash, err := a.Send(b.Address(), big.NewInt(300))
+	require.Nil(t, err)
+	assert.Equal(t, big.NewInt(300), s.Receivable(b.Address()))
+	require.Nil(t, b.ReceivePendings())
+	assert.Zero(t, s.Receivable(b.Address()).Sign())
+	balance, _, err := a.Balance()
+	require.Nil(t, err)
+	assert.Equal(t, big.NewInt(700), balance)
+	balance, _, err = b.Balance()
+	require.Nil(t, err)
+	assert.Equal(t, big.NewInt(300), balance)
+	info, err := s.BlockInfo(hash)
+	require.Nil(t, err)
+	assert.Equal(t, "send", info.Subtype)
+	assert.Equal(t, a.Address(), info.BlockAccount)
+	assert.Equal(t, b.Address(), info.Contents.LinkAsAccount)
+	ai, err := s.AccountInfo(a.Address())
+	require.Nil(t, err)
+	blocks, err := s.Successors(ai.OpenBlock, -1)
+	require.Nil(t, err)
+	assert.Equal(t, []rpc.BlockHash{ai.OpenBlock, hash}, blocks)
+}
+
+func TestChangeRep(t *testing.T) {
+	s := nanosim.NewServer()
+	defer s.Close()
+	a, b := newAccount(t, s, 1), newAccount(t, s, 2)
+	_, err := s.Fund(a.Address(), big.NewInt(1000))
+	require.Nil(t, err)
+	require.Nil(t, a.ReceivePendings())
+	hash, err := a.ChangeRep(b.Address())
+	require.Nil(t, err)
+	info, err := s.AccountInfo(a.Address())
+	require.Nil(t, err)
+	assert.Equal(t, b.Address(), info.Representative)
+	assert.Equal(t, hash, info.RepresentativeBlock)
+	block, err := s.BlockInfo(hash)
+	require.Nil(t, err)
+	assert.Equal(t, "change", block.Subtype)
+}
+
+func TestFork(t *testing.T) {
+	s := nanosim.NewServer()
+	defer s.Close()
+	a, b := newAccount(t, s, 1), newAccount(t, s, 2)
+	_, err := s.Fund(a.Address(), big.NewInt(1000))
+	require.Nil(t, err)
+	require.Nil(t, a.ReceivePendings())
+	block1, err := a.SendBlock(b.Address(), big.NewInt(1))
+	require.Nil(t, err)
+	block2, err := a.SendBlock(b.Address(), big.NewInt(2))
+	require.Nil(t, err)
+	client := rpc.Client{URL: s.URL}
+	_, err = client.Process(block1, "send")
+	require.Nil(t, err)
+	_, err = client.Process(block1, "send")
+	assert.EqualError(t, err, "Old block")
+	_, err = client.Process(block2, "send")
+	assert.EqualError(t, err, "Fork")
+}
+
+func TestConfirm(t *testing.T) {
+	s := nanosim.NewServer()
+	defer s.Close()
+	a := newAccount(t, s, 1)
+	s.SetAutoConfirm(false)
+	hash, err := s.Fund(a.Address(), big.NewInt(1000))
+	require.Nil(t, err)
+	info, err := s.BlockInfo(hash)
+	require.Nil(t, err)
+	assert.False(t, info.Confirmed)
+	require.Nil(t, a.ReceivePendings())
+	_, err = s.AccountInfo(a.Address())
+	assert.NotNil(t, err)
+	require.Nil(t, s.Confirm(hash))
+	info, err = s.BlockInfo(hash)
+	require.Nil(t, err)
+	assert.True(t, info.Confirmed)
+	require.Nil(t, a.ReceivePendings())
+	balance, _, err := a.Balance()
+	require.Nil(t, err)
+	assert.Equal(t, big.NewInt(1000), balance)
+}
@@ -0,0 +1,111 @@
+package nanosim
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/hectorchu/gonano/rpc"
+)
+
+// The methods below query and update the ledger in-process. They match
+// the node RPC calls of the same name, so a *Ledger can stand in for an
+// *rpc.Client.
+
+// AccountInfo retrieves information about an account.
+func (l *Ledger) AccountInfo(account string) (info rpc.AccountInfo, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.accountInfo(account)
+}
+
+// AccountsPending retrieves the confirmed pending blocks of accounts.
+func (l *Ledger) AccountsPending(accounts []string, count int64) (pending map[string]rpc.HashToPendingMap, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.accountsPending(accounts), nil
+}
+
+// ActiveDifficulty retrieves the difficulty of work required on the ledger,
+// which accepts any work.
+func (l *Ledger) ActiveDifficulty() (
+	multiplier float64,
+	networkCurrent, networkMinimum,
+	networkReceiveCurrent, networkReceiveMinimum rpc.HexData,
+	difficultyTrend []float64,
+	err error,
+) {
+	difficulty := make(rpc.HexData, 8)
+	return 1, difficulty, difficulty, difficulty, difficulty, []float64{1}, nil
+}
+
+// BlockInfo retrieves information about a block.
+func (l *Ledger) BlockInfo(hash rpc.BlockHash) (info rpc.BlockInfo, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.blockInfo(hash)
+}
+
+// BlocksInfo retrieves information about blocks.
+func (l *Ledger) BlocksInfo(hashes []rpc.BlockHash) (blocks map[string]*rpc.BlockInfo, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	blocks = make(map[string]*rpc.BlockInfo)
+	for _, hash := range hashes {
+		info, err := l.blockInfo(hash)
+		if err != nil {
+			return nil, err
+		}
+		blocks[hash.String()] = &info
+	}
+	return
+}
+
+// Blocks retrieves the contents of blocks.
+func (l *Ledger) Blocks(hashes []rpc.BlockHash) (blocks map[string]*rpc.Block, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	blocks = make(map[string]*rpc.Block)
+	for _, hash := range hashes {
+		info, err := l.blockInfo(hash)
+		if err != nil {
+			return nil, err
+		}
+		blocks[hash.String()] = info.Contents
+	}
+	return
+}
+
+// Ledger retrieves information about accounts, starting at account.
+func (l *Ledger) Ledger(account string, count int64, modifiedSince time.Time) (accounts map[string]rpc.AccountInfo, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.ledger(account, count, modifiedSince.Unix()), nil
+}
+
+// Process publishes a block to the ledger.
+func (l *Ledger) Process(block *rpc.Block, subtype string) (hash rpc.BlockHash, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.process(block, subtype)
+}
+
+// Successors retrieves a chain of blocks starting at block.
+func (l *Ledger) Successors(block rpc.BlockHash, count int64) (blocks []rpc.BlockHash, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.successors(block, count)
+}
+
+// WorkGenerate generates work for a block.
+func (l *Ledger) WorkGenerate(hash rpc.BlockHash, difficulty rpc.HexData) (
+	work, difficulty2 rpc.HexData, multiplier float64, err error,
+) {
+	return make(rpc.HexData, 8), difficulty, 1, nil
+}
+
+// Receivable gets the total amount receivable by an account.
+func (l *Ledger) Receivable(account string) (amount *big.Int) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.receivable(account)
+}
@@ -0,0 +1,146 @@
+package nanosim
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/hectorchu/gonano/rpc"
+)
+
+// Server serves a simulated ledger over a local HTTP server.
+type Server struct {
+	*Ledger
+	URL string
+	srv *httptest.Server
+}
+
+// NewServer starts a server for a new ledger.
+func NewServer() (s *Server) {
+	s = &Server{Ledger: NewLedger()}
+	s.srv = httptest.NewServer(s.Ledger)
+	s.URL = s.srv.URL
+	return
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+type request struct {
+	Action        string
+	Account       string
+	Accounts      []string
+	Hash          rpc.BlockHash
+	Hashes        []rpc.BlockHash
+	Block         json.RawMessage
+	Count         int64
+	ModifiedSince int64 `json:"modified_since"`
+	Subtype       string
+}
+
+// ServeHTTP handles node RPC requests.
+func (l *Ledger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	io.Copy(&buf, r.Body)
+	r.Body.Close()
+	var v request
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unable to parse JSON"})
+		return
+	}
+	result, err := l.handle(&v)
+	if err != nil {
+		result = map[string]string{"error": err.Error()}
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func (l *Ledger) handle(v *request) (result interface{}, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	switch v.Action {
+	case "account_balance":
+		balance := new(big.Int)
+		if info, err := l.accountInfo(v.Account); err == nil {
+			balance = &info.Balance.Int
+		}
+		pending := l.receivable(v.Account)
+		return map[string]string{
+			"balance":    balance.String(),
+			"pending":    pending.String(),
+			"receivable": pending.String(),
+		}, nil
+	case "account_info":
+		return l.accountInfo(v.Account)
+	case "accounts_pending":
+		return map[string]interface{}{"blocks": l.accountsPending(v.Accounts)}, nil
+	case "active_difficulty":
+		return map[string]interface{}{
+			"multiplier":              "1",
+			"network_current":         "0000000000000000",
+			"network_minimum":         "0000000000000000",
+			"network_receive_current": "0000000000000000",
+			"network_receive_minimum": "0000000000000000",
+			"difficulty_trend":        []string{"1"},
+		}, nil
+	case "block_info":
+		return l.blockInfo(v.Hash)
+	case "blocks":
+		blocks := make(map[string]*rpc.Block)
+		for _, hash := range v.Hashes {
+			info, err := l.blockInfo(hash)
+			if err != nil {
+				return nil, err
+			}
+			blocks[hash.String()] = info.Contents
+		}
+		return map[string]interface{}{"blocks": blocks}, nil
+	case "blocks_info":
+		blocks := make(map[string]rpc.BlockInfo)
+		for _, hash := range v.Hashes {
+			info, err := l.blockInfo(hash)
+			if err != nil {
+				return nil, err
+			}
+			blocks[hash.String()] = info
+		}
+		return map[string]interface{}{"blocks": blocks}, nil
+	case "ledger":
+		return map[string]interface{}{
+			"accounts": l.ledger(v.Account, v.Count, v.ModifiedSince),
+		}, nil
+	case "process":
+		var b rpc.Block
+		if err = json.Unmarshal(v.Block, &b); err != nil {
+			return
+		}
+		hash, err := l.process(&b, v.Subtype)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]rpc.BlockHash{"hash": hash}, nil
+	case "successors":
+		var hash rpc.BlockHash
+		if err = json.Unmarshal(v.Block, &hash); err != nil {
+			return
+		}
+		blocks, err := l.successors(hash, v.Count)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]rpc.BlockHash{"blocks": blocks}, nil
+	case "work_generate":
+		return map[string]string{
+			"work":       hex.EncodeToString(make([]byte, 8)),
+			"difficulty": "0000000000000000",
+			"multiplier": "1",
+		}, nil
+	}
+	return map[string]string{"error": "Unknown command"}, nil
+}
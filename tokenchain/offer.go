@@ -0,0 +1,269 @@
+package tokenchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+// ProposeOffer posts an open offer on-chain to swap an amount of tokens
+// for at least a minimum amount of the wanted token, expiring at the expiry.
+// The offer can be filled by any account.
+func ProposeOffer(c *Chain, a *wallet.Account, t *Token, amount *big.Int, want *Token, minAmount *big.Int, expiry Expiry) (s *Swap, err error) {
+	if err = c.Parse(); err != nil {
+		return
+	}
+	if amount.Sign() <= 0 {
+		return nil, errors.New("Amount is not positive")
+	}
+	if err = t.checkBalance(a.Address(), amount); err != nil {
+		return
+	}
+	if err = t.checkRestrictions(a.Address()); err != nil {
+		return
+	}
+	if err = checkPositive(minAmount); err != nil {
+		return
+	}
+	if minAmount.BitLen() > 128 {
+		return nil, errors.New("Amount out of range")
+	}
+	if t.c != want.c {
+		return nil, errors.New("Chain mismatch")
+	}
+	m := &offerMessage{
+		expiryHeight: expiry.Height,
+		amount:       amount,
+		minAmount:    minAmount,
+	}
+	if m.token, err = c.getHeight(t.hash); err != nil {
+		return
+	}
+	if m.want, err = c.getHeight(want.hash); err != nil {
+		return
+	}
+	hash, err := c.send(a, nil, m)
+	if err != nil {
+		return
+	}
+	return c.Swap(hash)
+}
+
+func (m *offerMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	want, ok := c.tokens[m.want]
+	if !ok {
+		return
+	}
+	if m.amount.Sign() <= 0 {
+		return
+	}
+	if t.checkBalance(info.BlockAccount, m.amount) != nil || t.checkRestrictions(info.BlockAccount) != nil {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 0)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil {
+		return false, nil
+	}
+	s := &Swap{
+		c:    c,
+		hash: hash,
+		left: SwapLeg{
+			Account: info.BlockAccount,
+			Token:   t,
+			Amount:  m.amount,
+		},
+		right: SwapLeg{
+			Token:  want,
+			Amount: m.minAmount,
+		},
+		remaining: m.amount,
+		expiry:    Expiry{Height: m.expiryHeight},
+		offer:     true,
+	}
+	c.swaps[height] = s
+	c.emit(&SwapProposed{s.event(hash, height, info.BlockAccount)})
+	return
+}
+
+// cost returns the amount of the wanted token due for the next amount of
+// the offered token at the offer's price. The price of the total filled is
+// rounded up in the proposer's favour, so filling an offer in parts costs
+// the same as filling it at once. Offers of nothing, which older state may
+// hold, cost nothing.
+func (s *Swap) cost(amount *big.Int) *big.Int {
+	if s.left.Amount.Sign() == 0 {
+		return new(big.Int)
+	}
+	filled := new(big.Int).Sub(s.left.Amount, s.remaining)
+	cost := s.price(new(big.Int).Add(filled, amount))
+	return cost.Sub(cost, s.price(filled))
+}
+
+// price returns the amount of the wanted token due for an amount of the
+// offered token, rounded up.
+func (s *Swap) price(amount *big.Int) *big.Int {
+	price := new(big.Int).Mul(amount, s.right.Amount)
+	price.Add(price, s.left.Amount).Sub(price, big.NewInt(1))
+	return price.Quo(price, s.left.Amount)
+}
+
+func (s *Swap) fill(hash rpc.BlockHash, height uint32, account string, amount, paid *big.Int) {
+	balance := s.left.Token.Balance(s.left.Account)
+	s.left.Token.setBalance(s.left.Account, balance.Sub(balance, amount))
+	balance = s.left.Token.Balance(account)
+	s.left.Token.setBalance(account, balance.Add(balance, amount))
+	balance = s.right.Token.Balance(account)
+	s.right.Token.setBalance(account, balance.Sub(balance, paid))
+	balance = s.right.Token.Balance(s.left.Account)
+	s.right.Token.setBalance(s.left.Account, balance.Add(balance, paid))
+	s.fills = append(s.fills, SwapFill{
+		Hash:    hash,
+		Account: account,
+		Amount:  amount,
+		Paid:    paid,
+	})
+	s.remaining = new(big.Int).Sub(s.remaining, amount)
+	s.inactive = s.remaining.Sign() == 0
+	s.c.emit(&SwapConfirmed{s.event(hash, height, account)})
+}
+
+// Fill fills the remainder of an open offer, paying an amount of the wanted
+// token that is at least the remainder's share of the minimum amount.
+func (s *Swap) Fill(a *wallet.Account, amount *big.Int) (hash rpc.BlockHash, err error) {
+	if err = s.c.Parse(); err != nil {
+		return
+	}
+	if err = s.checkFill(a.Address(), amount); err != nil {
+		return
+	}
+	if err = s.checkExpiry(); err != nil {
+		return
+	}
+	height, err := s.c.getHeight(s.hash)
+	if err != nil {
+		return
+	}
+	return s.c.send(a, nil, &fillMessage{
+		offer:  height,
+		amount: amount,
+	})
+}
+
+func (s *Swap) checkFill(account string, amount *big.Int) (err error) {
+	if s.inactive {
+		return errors.New("Swap is inactive")
+	}
+	if !s.offer {
+		return errors.New("Swap is not an offer")
+	}
+	if amount.Cmp(s.cost(s.remaining)) < 0 {
+		return errors.New("Amount is below minimum")
+	}
+	if err = s.left.Token.checkBalance(s.left.Account, s.remaining); err != nil {
+		return
+	}
+	if err = s.checkFillRestrictions(account); err != nil {
+		return
+	}
+	return s.right.Token.checkBalance(account, amount)
+}
+
+func (m *fillMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	s, ok := c.swaps[m.offer]
+	if !ok {
+		return
+	}
+	if s.checkFill(info.BlockAccount, m.amount) != nil {
+		return
+	}
+	s.fill(hash, height, info.BlockAccount, s.remaining, m.amount)
+	delete(c.swaps, m.offer)
+	return true, nil
+}
+
+// FillPartial fills part of an open offer, taking an amount of the offered
+// token and paying for it at the offer's price. The remainder stays open.
+func (s *Swap) FillPartial(a *wallet.Account, amount *big.Int) (hash rpc.BlockHash, err error) {
+	if err = s.c.Parse(); err != nil {
+		return
+	}
+	if err = s.checkFillPartial(a.Address(), amount); err != nil {
+		return
+	}
+	if err = s.checkExpiry(); err != nil {
+		return
+	}
+	height, err := s.c.getHeight(s.hash)
+	if err != nil {
+		return
+	}
+	return s.c.send(a, nil, &partialFillMessage{
+		offer:  height,
+		amount: amount,
+	})
+}
+
+func (s *Swap) checkFillPartial(account string, amount *big.Int) (err error) {
+	if s.inactive {
+		return errors.New("Swap is inactive")
+	}
+	if !s.offer {
+		return errors.New("Swap is not an offer")
+	}
+	if amount.Sign() <= 0 {
+		return errors.New("Amount is not positive")
+	}
+	if amount.Cmp(s.remaining) > 0 {
+		return errors.New("Amount exceeds remaining")
+	}
+	if err = s.left.Token.checkBalance(s.left.Account, amount); err != nil {
+		return
+	}
+	if err = s.checkFillRestrictions(account); err != nil {
+		return
+	}
+	return s.right.Token.checkBalance(account, s.cost(amount))
+}
+
+func (s *Swap) checkFillRestrictions(account string) (err error) {
+	if err = s.left.Token.checkRestrictions(s.left.Account, account); err != nil {
+		return
+	}
+	return s.right.Token.checkRestrictions(account, s.left.Account)
+}
+
+func (m *partialFillMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	s, ok := c.swaps[m.offer]
+	if !ok {
+		return
+	}
+	if s.checkFillPartial(info.BlockAccount, m.amount) != nil {
+		return
+	}
+	s.fill(hash, height, info.BlockAccount, m.amount, s.cost(m.amount))
+	if s.inactive {
+		delete(c.swaps, m.offer)
+	}
+	return true, nil
+}
+
+// Offers gets the chain's open offers.
+func (c *Chain) Offers() (offers map[string]*Swap) {
+	offers = make(map[string]*Swap)
+	for _, s := range c.swaps {
+		if s.offer {
+			offers[string(s.Hash())] = s
+		}
+	}
+	return
+}
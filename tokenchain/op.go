@@ -0,0 +1,209 @@
+package tokenchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+// CustomOpFirst is the first op of the range reserved for custom ops.
+// Ops below it are reserved for the protocol.
+const CustomOpFirst = 0xc0
+
+// Message represents a custom op. Its payload is the 28 bytes following
+// the preamble and op in the message block.
+//
+// Process returns valid as false to reject an op. A non-nil err stops Parse
+// at the op's block, which is retried by every later Parse, so it must be
+// reserved for transient failures such as node errors. Process reaches the
+// chain only through ctx; calls that parse, send or save the chain fail
+// while it is being parsed.
+type Message interface {
+	Op() byte
+	Serialize() []byte
+	Deserialize(data []byte)
+	Process(ctx *OpContext) (valid bool, err error)
+}
+
+// ExtendedMessage is a custom op carrying extension data in continuation
+// blocks sent ahead of it.
+type ExtendedMessage interface {
+	Message
+	Extension() ([]byte, error)
+	SetExtension(data []byte) error
+}
+
+var (
+	opsMutex sync.RWMutex
+	ops      = make(map[byte]func() Message)
+)
+
+// RegisterOp registers a factory for a custom op in the reserved range so
+// that chains parse and process it.
+func RegisterOp(op byte, factory func() Message) (err error) {
+	if op < CustomOpFirst {
+		return errors.New("Op is outside the custom range")
+	}
+	opsMutex.Lock()
+	defer opsMutex.Unlock()
+	if _, ok := ops[op]; ok {
+		return errors.New("Op already registered")
+	}
+	ops[op] = factory
+	return
+}
+
+func newCustomMessage(op byte) (m message, ok bool) {
+	opsMutex.RLock()
+	factory, ok := ops[op]
+	opsMutex.RUnlock()
+	if !ok {
+		return
+	}
+	return wrapMessage(factory()), true
+}
+
+func wrapMessage(m Message) message {
+	if m, ok := m.(ExtendedMessage); ok {
+		return &customExtendedMessage{customMessage{m}, m}
+	}
+	return &customMessage{m}
+}
+
+type customMessage struct {
+	m Message
+}
+
+func (cm *customMessage) serialize() []byte {
+	buf := newMessageBuffer(cm.m.Op())
+	payload := make([]byte, 32-buf.Len())
+	copy(payload, cm.m.Serialize())
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func (cm *customMessage) deserialize(data []byte) {
+	cm.m.Deserialize(data)
+}
+
+func (cm *customMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	return cm.m.Process(&OpContext{ctx: ctx, c: c, hash: hash, height: height, info: info})
+}
+
+type customExtendedMessage struct {
+	customMessage
+	em ExtendedMessage
+}
+
+func (cm *customExtendedMessage) extension() ([]byte, error) {
+	return cm.em.Extension()
+}
+
+func (cm *customExtendedMessage) setExtension(data []byte) error {
+	return cm.em.SetExtension(data)
+}
+
+// Send sends a custom op on the chain from an account, with a send of
+// 1 raw to each destination.
+func (c *Chain) Send(a *wallet.Account, destinations []string, m Message) (hash rpc.BlockHash, err error) {
+	if err = c.Parse(); err != nil {
+		return
+	}
+	opsMutex.RLock()
+	_, ok := ops[m.Op()]
+	opsMutex.RUnlock()
+	if !ok {
+		return nil, errors.New("Op not registered")
+	}
+	return c.send(a, destinations, wrapMessage(m))
+}
+
+// BlockHeight gets the height of a block on the chain, by which custom ops
+// can refer to tokens and other objects created at that block.
+func (c *Chain) BlockHeight(hash rpc.BlockHash) (height uint32, err error) {
+	return c.getHeight(hash)
+}
+
+// OpContext gives a custom op controlled access to the chain while it is
+// processed. It must not be retained after Process returns.
+type OpContext struct {
+	ctx    context.Context
+	c      *Chain
+	hash   rpc.BlockHash
+	height uint32
+	info   rpc.BlockInfo
+}
+
+// Hash returns the block hash of the op.
+func (ctx *OpContext) Hash() rpc.BlockHash {
+	return ctx.hash
+}
+
+// Height returns the chain height of the op.
+func (ctx *OpContext) Height() uint32 {
+	return ctx.height
+}
+
+// Account returns the account that sent the op.
+func (ctx *OpContext) Account() string {
+	return ctx.info.BlockAccount
+}
+
+// Destinations gets the accounts of the n destination sends of the op.
+func (ctx *OpContext) Destinations(n int) (accounts []string, valid bool, err error) {
+	return ctx.c.getDestinations(ctx.ctx, ctx.info.Contents, n)
+}
+
+// Extension gets the extension data of the op, sent ahead of its n
+// destination sends.
+func (ctx *OpContext) Extension(n int) (data []byte, valid bool, err error) {
+	return ctx.c.getExtension(ctx.ctx, ctx.info.Contents, n)
+}
+
+// Token gets the token created at a chain height.
+func (ctx *OpContext) Token(height uint32) (t *Token, err error) {
+	t, ok := ctx.c.tokens[height]
+	if !ok {
+		return nil, errors.New("Token not found")
+	}
+	return
+}
+
+// Transfer transfers an amount of tokens from the sending account to
+// another account, subject to the same checks as a transfer.
+func (ctx *OpContext) Transfer(t *Token, account string, amount *big.Int) (err error) {
+	if err = t.checkBalance(ctx.Account(), amount); err != nil {
+		return
+	}
+	if err = t.checkRestrictions(ctx.Account(), account); err != nil {
+		return
+	}
+	t.transfer(ctx.hash, ctx.Account(), account, amount, "")
+	return
+}
+
+// Mint mints an amount of new tokens to an account. The sending account
+// must be the mint authority or the owner.
+func (ctx *OpContext) Mint(t *Token, account string, amount *big.Int) (err error) {
+	if err = t.checkMint(ctx.Account(), amount); err != nil {
+		return
+	}
+	if err = t.checkRestrictions(account); err != nil {
+		return
+	}
+	t.mint(account, amount)
+	return
+}
+
+// Burn destroys an amount of the sending account's tokens.
+func (ctx *OpContext) Burn(t *Token, amount *big.Int) (err error) {
+	if err = t.checkBalance(ctx.Account(), amount); err != nil {
+		return
+	}
+	t.burn(ctx.Account(), amount)
+	return
+}
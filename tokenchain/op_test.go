@@ -0,0 +1,98 @@
+package tokenchain_test
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rewardOp = tokenchain.CustomOpFirst + 1
+
+// rewardMessage pays a reward in tokens plus a bonus percentage.
+type rewardMessage struct {
+	token  uint32
+	amount *big.Int
+	bonus  []byte
+}
+
+func (m *rewardMessage) Op() byte {
+	return rewardOp
+}
+
+func (m *rewardMessage) Serialize() []byte {
+	data := make([]byte, 28)
+	binary.BigEndian.PutUint32(data, m.token)
+	m.amount.FillBytes(data[12:])
+	return data
+}
+
+func (m *rewardMessage) Deserialize(data []byte) {
+	m.token = binary.BigEndian.Uint32(data)
+	m.amount = new(big.Int).SetBytes(data[12:])
+}
+
+func (m *rewardMessage) Extension() ([]byte, error) {
+	return m.bonus, nil
+}
+
+func (m *rewardMessage) SetExtension(data []byte) error {
+	m.bonus = data[:1]
+	return nil
+}
+
+func (m *rewardMessage) Process(ctx *tokenchain.OpContext) (valid bool, err error) {
+	t, err := ctx.Token(m.token)
+	if err != nil {
+		return false, nil
+	}
+	accounts, valid, err := ctx.Destinations(1)
+	if !valid {
+		return
+	}
+	data, valid, err := ctx.Extension(1)
+	if !valid {
+		return
+	}
+	if m.SetExtension(data) != nil {
+		return false, nil
+	}
+	amount := new(big.Int).Mul(m.amount, big.NewInt(int64(100+int(m.bonus[0]))))
+	amount.Quo(amount, big.NewInt(100))
+	return ctx.Transfer(t, accounts[0], amount) == nil, nil
+}
+
+func TestRegisterOp(t *testing.T) {
+	factory := func() tokenchain.Message { return new(rewardMessage) }
+	assert.NotNil(t, tokenchain.RegisterOp(2, factory))
+	require.Nil(t, tokenchain.RegisterOp(rewardOp, factory))
+	assert.NotNil(t, tokenchain.RegisterOp(rewardOp, factory))
+	var (
+		chain  = newChain(t)
+		token  = genesis(t, chain, getAccount(0))
+		amount = big.NewInt(1000)
+	)
+	height, err := chain.BlockHeight(token.Hash())
+	require.Nil(t, err)
+	_, err = chain.Send(getAccount(0), []string{getAccount(1).Address()}, &rewardMessage{
+		token:  height,
+		amount: amount,
+		bonus:  []byte{10},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, big.NewInt(1100), token.Balance(getAccount(1).Address()))
+	transfers := token.Transfers(getAccount(1).Address())
+	require.Len(t, transfers, 1)
+	assert.Equal(t, getAccount(0).Address(), transfers[0].From)
+	_, err = chain.Send(getAccount(1), []string{getAccount(0).Address()}, &rewardMessage{
+		token:  height,
+		amount: amount,
+		bonus:  []byte{20},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, big.NewInt(1100), token.Balance(getAccount(1).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+}
@@ -0,0 +1,136 @@
+package tokenchain
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/util"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+const (
+	ownerTransfer = iota + 1
+	ownerPropose
+	ownerAccept
+	ownerWithdraw
+)
+
+// Owner returns the account holding the issuer rights of the token,
+// initially its issuer.
+func (t *Token) Owner() string {
+	return t.owner
+}
+
+// PendingOwner returns the account proposed as the next owner, or an empty
+// string if there is no proposal.
+func (t *Token) PendingOwner() string {
+	return t.pendingOwner
+}
+
+// TransferOwnership moves the issuer rights of the token to another account.
+// The mint authority and admin roles move with them if held by the owner.
+func (t *Token) TransferOwnership(a *wallet.Account, account string) (hash rpc.BlockHash, err error) {
+	return t.sendOwner(a, ownerTransfer, account)
+}
+
+// ProposeOwner proposes another account as the owner of the token, which
+// takes effect once that account accepts. A later proposal replaces it.
+func (t *Token) ProposeOwner(a *wallet.Account, account string) (hash rpc.BlockHash, err error) {
+	return t.sendOwner(a, ownerPropose, account)
+}
+
+// AcceptOwnership accepts the ownership of the token as the pending owner.
+func (t *Token) AcceptOwnership(a *wallet.Account) (hash rpc.BlockHash, err error) {
+	return t.sendOwner(a, ownerAccept, "")
+}
+
+// WithdrawOwnerProposal withdraws the pending owner proposal.
+func (t *Token) WithdrawOwnerProposal(a *wallet.Account) (hash rpc.BlockHash, err error) {
+	return t.sendOwner(a, ownerWithdraw, "")
+}
+
+func (t *Token) sendOwner(a *wallet.Account, action byte, account string) (hash rpc.BlockHash, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if err = t.checkOwner(a.Address(), action); err != nil {
+		return
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	var destinations []string
+	if action == ownerTransfer || action == ownerPropose {
+		if _, err = util.AddressToPubkey(account); err != nil {
+			return
+		}
+		destinations = []string{account}
+	}
+	return t.c.send(a, destinations, &ownerMessage{
+		token:  height,
+		action: action,
+	})
+}
+
+func (t *Token) checkOwner(account string, action byte) (err error) {
+	switch action {
+	case ownerTransfer, ownerPropose:
+		if account != t.owner {
+			err = errors.New("Must use owner account")
+		}
+	case ownerAccept:
+		if t.pendingOwner == "" || account != t.pendingOwner {
+			err = errors.New("Must accept with pending owner account")
+		}
+	case ownerWithdraw:
+		if account != t.owner {
+			err = errors.New("Must use owner account")
+		} else if t.pendingOwner == "" {
+			err = errors.New("No pending owner")
+		}
+	default:
+		err = errors.New("Unknown owner action")
+	}
+	return
+}
+
+func (t *Token) setOwner(account string) {
+	if t.mintAuthority == t.owner {
+		t.mintAuthority = account
+	}
+	if t.admin == t.owner {
+		t.admin = account
+	}
+	t.owner = account
+	t.pendingOwner = ""
+}
+
+func (m *ownerMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if t.checkOwner(info.BlockAccount, m.action) != nil {
+		return
+	}
+	switch m.action {
+	case ownerAccept:
+		t.setOwner(info.BlockAccount)
+		return true, nil
+	case ownerWithdraw:
+		t.pendingOwner = ""
+		return true, nil
+	}
+	account, valid, err := c.getDestination(ctx, info.Contents)
+	if !valid {
+		return
+	}
+	if m.action == ownerTransfer {
+		t.setOwner(account)
+	} else {
+		t.pendingOwner = account
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package tokenchain_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnership(t *testing.T) {
+	var (
+		chain = newChain(t)
+		owner = getAccount(0)
+		team  = getAccount(1)
+		opts  = tokenchain.TokenOptions{MintAuthority: owner.Address(), Admin: true}
+	)
+	token, err := tokenchain.TokenGenesisWithOptions(chain, owner, "TOKEN", supply, 5, opts)
+	require.Nil(t, err)
+	assert.Equal(t, owner.Address(), token.Owner())
+	_, err = token.TransferOwnership(team, team.Address())
+	assert.NotNil(t, err)
+	_, err = token.AcceptOwnership(team)
+	assert.NotNil(t, err)
+	_, err = token.ProposeOwner(owner, "")
+	assert.NotNil(t, err)
+	_, err = token.TransferOwnership(owner, "nano_1invalid")
+	assert.NotNil(t, err)
+	_, err = token.WithdrawOwnerProposal(owner)
+	assert.NotNil(t, err)
+	_, err = token.ProposeOwner(owner, payee(t, 0))
+	require.Nil(t, err)
+	_, err = token.WithdrawOwnerProposal(team)
+	assert.NotNil(t, err)
+	_, err = token.WithdrawOwnerProposal(owner)
+	require.Nil(t, err)
+	assert.Equal(t, "", token.PendingOwner())
+	_, err = token.ProposeOwner(owner, team.Address())
+	require.Nil(t, err)
+	assert.Equal(t, team.Address(), token.PendingOwner())
+	assert.Equal(t, owner.Address(), token.Owner())
+	_, err = token.AcceptOwnership(owner)
+	assert.NotNil(t, err)
+	assertEqualOwnership(t, token, loadChain(t, chain.Address()))
+	assertEqualOwnership(t, token, restoreChain(t, chain))
+	_, err = token.AcceptOwnership(team)
+	require.Nil(t, err)
+	assert.Equal(t, team.Address(), token.Owner())
+	assert.Equal(t, "", token.PendingOwner())
+	assert.Equal(t, team.Address(), token.MintAuthority())
+	assert.Equal(t, team.Address(), token.Admin())
+	assert.Equal(t, owner.Address(), token.Issuer())
+	_, err = token.Mint(owner, owner.Address(), big.NewInt(1))
+	assert.NotNil(t, err)
+	_, err = token.Mint(team, team.Address(), big.NewInt(1))
+	require.Nil(t, err)
+	_, err = token.SetMetadata(owner, tokenchain.Metadata{Name: "Token"})
+	assert.NotNil(t, err)
+	_, err = token.TransferOwnership(team, owner.Address())
+	require.Nil(t, err)
+	assert.Equal(t, owner.Address(), token.Owner())
+	assert.Equal(t, owner.Address(), token.Admin())
+	_, err = token.SetMetadata(owner, tokenchain.Metadata{Name: "Token"})
+	require.Nil(t, err)
+	assertEqualOwnership(t, token, loadChain(t, chain.Address()))
+	assertEqualOwnership(t, token, restoreChain(t, chain))
+}
+
+func assertEqualOwnership(t *testing.T, t1 *tokenchain.Token, c2 *tokenchain.Chain) {
+	t2, err := c2.Token(t1.Hash())
+	require.Nil(t, err)
+	assertEqualToken(t, t1, t2)
+	assert.Equal(t, t1.PendingOwner(), t2.PendingOwner())
+	assert.Equal(t, t1.Admin(), t2.Admin())
+}
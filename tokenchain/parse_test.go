@@ -0,0 +1,60 @@
+package tokenchain_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend counts single and bulk block requests.
+type countingBackend struct {
+	tokenchain.NodeBackend
+	blocks, batches int
+}
+
+func (b *countingBackend) BlockInfo(hash rpc.BlockHash) (info rpc.BlockInfo, err error) {
+	b.blocks++
+	return b.NodeBackend.BlockInfo(hash)
+}
+
+func (b *countingBackend) BlocksInfo(hashes []rpc.BlockHash) (blocks map[string]*rpc.BlockInfo, err error) {
+	b.batches++
+	return b.NodeBackend.BlocksInfo(hashes)
+}
+
+func TestParseBatches(t *testing.T) {
+	chain := newChain(t)
+	token := genesis(t, chain, getAccount(0))
+	for i := 0; i < 3; i++ {
+		_, err := token.Transfer(getAccount(0), getAccount(1).Address(), big.NewInt(1000))
+		require.Nil(t, err)
+	}
+	payouts := make([]tokenchain.Payout, 10)
+	for i := range payouts {
+		payouts[i] = tokenchain.Payout{Account: payee(t, i), Amount: big.NewInt(1000)}
+	}
+	_, _, err := token.TransferMany(getAccount(0), payouts)
+	require.Nil(t, err)
+	backend := &countingBackend{NodeBackend: sim.Ledger}
+	chain2, err := tokenchain.LoadChainWithBackend(chain.Address(), backend)
+	require.Nil(t, err)
+	backend.blocks = 0
+	var heights, totals []uint32
+	chain2.SetProgress(func(height, total uint32) {
+		heights = append(heights, height)
+		totals = append(totals, total)
+	})
+	require.Nil(t, chain2.Parse())
+	assertEqualChain(t, chain, chain2)
+	assert.Zero(t, backend.blocks)
+	assert.Less(t, backend.batches, 20)
+	require.Len(t, heights, 1)
+	assert.Equal(t, totals[0], heights[0])
+	height, err := chain.BlockHeight(token.Hash())
+	require.Nil(t, err)
+	assert.Greater(t, heights[0], height)
+}
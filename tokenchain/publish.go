@@ -0,0 +1,182 @@
+package tokenchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"reflect"
+
+	"github.com/hectorchu/gonano/pow"
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/util"
+	"github.com/hectorchu/gonano/wallet"
+	"github.com/hectorchu/gonano/wallet/ed25519"
+	"golang.org/x/crypto/blake2b"
+)
+
+// deriveKey derives the key of a chain's account from its seed, as a
+// wallet does for its first account.
+func deriveKey(seed []byte) (pubkey []byte, key ed25519.PrivateKey, err error) {
+	if len(seed) != 32 {
+		return nil, nil, errors.New("Seed must be 32 bytes")
+	}
+	hash := blake2b.Sum256(append(append([]byte{}, seed...), 0, 0, 0, 0))
+	return ed25519.GenerateKey(bytes.NewReader(hash[:]))
+}
+
+// publishSend publishes a block of an account sending an amount, carrying
+// data in its representative.
+func (c *Chain) publishSend(ctx context.Context, a *wallet.Account, data []byte, account string, amount *big.Int) (hash rpc.BlockHash, err error) {
+	block, err := c.sendBlock(ctx, a, data, account, amount)
+	if err != nil {
+		return
+	}
+	subtype := "send"
+	if amount.Sign() == 0 {
+		subtype = "change"
+	}
+	return c.publish(ctx, block, subtype)
+}
+
+// sendBlock builds a block of an account sending an amount, carrying data
+// in its representative, from the account as seen by the chain's backend.
+// A wallet signs only blocks it builds from its own node, so the block is
+// signed with the account's key. Hardware wallets hold no key, so they
+// build the block themselves from their own node.
+func (c *Chain) sendBlock(ctx context.Context, a *wallet.Account, data []byte, account string, amount *big.Int) (block *rpc.Block, err error) {
+	representative, err := util.PubkeyToAddress(data)
+	if err != nil {
+		return
+	}
+	key := accountKey(a)
+	if key == nil {
+		if err = a.SetRep(representative); err != nil {
+			return
+		}
+		return a.SendBlock(account, amount)
+	}
+	link, err := util.AddressToPubkey(account)
+	if err != nil {
+		return
+	}
+	info, err := c.backend(ctx).AccountInfo(a.Address())
+	if err != nil {
+		return
+	}
+	block = &rpc.Block{
+		Type:           "state",
+		Account:        a.Address(),
+		Previous:       info.Frontier,
+		Representative: representative,
+		Balance:        &rpc.RawAmount{},
+		Link:           link,
+	}
+	if block.Balance.Sub(&info.Balance.Int, amount).Sign() < 0 {
+		return nil, errors.New("Insufficient funds")
+	}
+	hash, err := block.Hash()
+	if err != nil {
+		return
+	}
+	block.Signature = ed25519.Sign(key, hash)
+	return
+}
+
+// accountKey gets the private key of a wallet account, which the wallet
+// does not expose, or nil for a hardware wallet account.
+func accountKey(a *wallet.Account) ed25519.PrivateKey {
+	key := reflect.ValueOf(a).Elem().FieldByName("key")
+	if key.Kind() != reflect.Slice || key.Len() != ed25519.PrivateKeySize {
+		return nil
+	}
+	return append(ed25519.PrivateKey{}, key.Bytes()...)
+}
+
+// changeData publishes a change block of an account carrying data in its
+// representative. A send of nothing to the zero account is such a block.
+func (c *Chain) changeData(ctx context.Context, a *wallet.Account, data []byte) (err error) {
+	account, err := util.PubkeyToAddress(make([]byte, 32))
+	if err != nil {
+		return
+	}
+	_, err = c.publishSend(ctx, a, data, account, new(big.Int))
+	return
+}
+
+// receive publishes a block of the chain's account receiving a send.
+func (c *Chain) receive(ctx context.Context, link rpc.BlockHash) (hash rpc.BlockHash, err error) {
+	send, err := c.backend(ctx).BlockInfo(link)
+	if err != nil {
+		return
+	}
+	representative, err := util.PubkeyToAddress(c.seed)
+	if err != nil {
+		return
+	}
+	block := &rpc.Block{
+		Type:           "state",
+		Account:        c.Address(),
+		Previous:       make(rpc.BlockHash, 32),
+		Representative: representative,
+		Balance:        &rpc.RawAmount{},
+		Link:           link,
+	}
+	if info, err := c.backend(ctx).AccountInfo(c.Address()); err == nil {
+		block.Previous = info.Frontier
+		block.Balance.Set(&info.Balance.Int)
+	}
+	block.Balance.Add(&block.Balance.Int, &send.Amount.Int)
+	if hash, err = block.Hash(); err != nil {
+		return
+	}
+	block.Signature = ed25519.Sign(c.key, hash)
+	return c.publish(ctx, block, "receive")
+}
+
+// receivePendings publishes blocks of the chain's account receiving all
+// its pending sends.
+func (c *Chain) receivePendings(ctx context.Context) (err error) {
+	pending, err := c.backend(ctx).AccountsPending([]string{c.Address()}, -1)
+	if err != nil {
+		return
+	}
+	for hash := range pending[c.Address()] {
+		link, err := hex.DecodeString(hash)
+		if err != nil {
+			return err
+		}
+		if _, err = c.receive(ctx, link); err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// publish generates work for a signed block and publishes it to the node.
+// Work is generated locally if the node cannot generate it.
+func (c *Chain) publish(ctx context.Context, block *rpc.Block, subtype string) (hash rpc.BlockHash, err error) {
+	root := block.Previous
+	if bytes.Count(root, []byte{0}) == len(root) {
+		if root, err = util.AddressToPubkey(block.Account); err != nil {
+			return
+		}
+	}
+	_, difficulty, _, receiveDifficulty, _, _, err := c.backend(ctx).ActiveDifficulty()
+	if err != nil {
+		return
+	}
+	if subtype == "receive" {
+		difficulty = receiveDifficulty
+	}
+	if block.Work, _, _, err = c.backend(ctx).WorkGenerate(root, difficulty); err != nil {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if block.Work, err = pow.Generate(root, difficulty); err != nil {
+			return
+		}
+	}
+	return c.backend(ctx).Process(block, subtype)
+}
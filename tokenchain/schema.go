@@ -0,0 +1,126 @@
+package tokenchain
+
+import "database/sql"
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS chains (seed TEXT PRIMARY KEY, frontier TEXT)`,
+	`CREATE TABLE IF NOT EXISTS tokens
+	(hash TEXT PRIMARY KEY, chain TEXT, height INTEGER, issuer TEXT, owner TEXT, pending_owner TEXT,
+	name TEXT, supply TEXT, burned TEXT, decimals INTEGER, mint_authority TEXT)`,
+	`CREATE TABLE IF NOT EXISTS token_balances
+	(hash TEXT, account TEXT, balance TEXT, PRIMARY KEY (hash, account))`,
+	`CREATE TABLE IF NOT EXISTS token_allowances
+	(hash TEXT, owner TEXT, spender TEXT, allowance TEXT, PRIMARY KEY (hash, owner, spender))`,
+	`CREATE TABLE IF NOT EXISTS token_metadata
+	(hash TEXT PRIMARY KEY, name TEXT, symbol TEXT, description TEXT, uri TEXT)`,
+	`CREATE TABLE IF NOT EXISTS token_metadata_history
+	(hash TEXT, seq INTEGER, block TEXT, token_name TEXT,
+	name TEXT, symbol TEXT, description TEXT, uri TEXT, PRIMARY KEY (hash, seq))`,
+	`CREATE TABLE IF NOT EXISTS token_vestings
+	(hash TEXT, account TEXT, seq INTEGER, amount TEXT,
+	start_point INTEGER, cliff_point INTEGER, end_point INTEGER, PRIMARY KEY (hash, account, seq))`,
+	`CREATE TABLE IF NOT EXISTS token_transfers
+	(hash TEXT, seq INTEGER, block TEXT, sender TEXT, recipient TEXT, amount TEXT, memo TEXT,
+	PRIMARY KEY (hash, seq))`,
+	`CREATE TABLE IF NOT EXISTS token_governance
+	(hash TEXT PRIMARY KEY, admin TEXT, paused INTEGER)`,
+	`CREATE TABLE IF NOT EXISTS token_frozen
+	(hash TEXT, account TEXT, frozen INTEGER, PRIMARY KEY (hash, account))`,
+	`CREATE TABLE IF NOT EXISTS swaps
+	(hash TEXT PRIMARY KEY, chain TEXT, height INTEGER,
+	left_account TEXT, left_token TEXT, left_amount TEXT,
+	right_account TEXT, right_token TEXT, right_amount TEXT,
+	remaining TEXT, expiry_height INTEGER, offer INTEGER, nano INTEGER, active INTEGER)`,
+	`CREATE TABLE IF NOT EXISTS swap_fills
+	(swap TEXT, seq INTEGER, hash TEXT, account TEXT, amount TEXT, paid TEXT, PRIMARY KEY (swap, seq))`,
+	`CREATE TABLE IF NOT EXISTS htlcs
+	(hash TEXT PRIMARY KEY, chain TEXT, height INTEGER, token TEXT, sender TEXT, recipient TEXT,
+	amount TEXT, hashlock TEXT, timeout_height INTEGER,
+	preimage TEXT, expired INTEGER, refunded INTEGER)`,
+	`CREATE TABLE IF NOT EXISTS collections
+	(hash TEXT PRIMARY KEY, chain TEXT, height INTEGER, issuer TEXT, name TEXT)`,
+	`CREATE TABLE IF NOT EXISTS collection_items
+	(hash TEXT, id INTEGER, owner TEXT, content_hash TEXT, PRIMARY KEY (hash, id))`,
+	`CREATE TABLE IF NOT EXISTS multi_tokens
+	(hash TEXT PRIMARY KEY, chain TEXT, height INTEGER, issuer TEXT, name TEXT)`,
+	`CREATE TABLE IF NOT EXISTS multi_token_classes
+	(hash TEXT, class INTEGER, supply TEXT, PRIMARY KEY (hash, class))`,
+	`CREATE TABLE IF NOT EXISTS multi_token_balances
+	(hash TEXT, class INTEGER, account TEXT, balance TEXT, PRIMARY KEY (hash, class, account))`,
+}
+
+// migrations add the columns that tables gained after they were first
+// released, with an optional statement to backfill existing rows. Token
+// issuers and owners missing from old rows are recovered from the ledger
+// when the token is loaded.
+var migrations = []struct {
+	table, column, decl, backfill string
+}{
+	{"tokens", "mint_authority", "TEXT DEFAULT ''", ""},
+	{"tokens", "burned", "TEXT DEFAULT '0'", ""},
+	{"tokens", "issuer", "TEXT DEFAULT ''", ""},
+	{"tokens", "owner", "TEXT DEFAULT ''", ""},
+	{"tokens", "pending_owner", "TEXT DEFAULT ''", ""},
+	{"swaps", "expiry_height", "INTEGER DEFAULT 0", ""},
+	{"swaps", "offer", "INTEGER DEFAULT 0", ""},
+	{"swaps", "remaining", "TEXT", "UPDATE swaps SET remaining = left_amount WHERE remaining IS NULL"},
+	{"swaps", "nano", "INTEGER DEFAULT 0", ""},
+}
+
+// migrate creates any missing tables and adds any missing columns.
+func migrate(tx *sql.Tx) (err error) {
+	for _, stmt := range schema {
+		if _, err = tx.Exec(stmt); err != nil {
+			return
+		}
+	}
+	for _, m := range migrations {
+		var ok bool
+		if ok, err = hasColumn(tx, m.table, m.column); err != nil {
+			return
+		}
+		if ok {
+			continue
+		}
+		if _, err = tx.Exec("ALTER TABLE " + m.table + " ADD COLUMN " + m.column + " " + m.decl); err != nil {
+			return
+		}
+		if m.backfill != "" {
+			if _, err = tx.Exec(m.backfill); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func hasColumn(tx *sql.Tx, table, column string) (ok bool, err error) {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return
+		}
+		if name == column {
+			ok = true
+		}
+	}
+	return ok, rows.Err()
+}
+
+// migrateDB runs migrate in its own transaction.
+func migrateDB(db *sql.DB) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return
+	}
+	if err = migrate(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
@@ -1,8 +1,12 @@
 package tokenchain
 
 import (
+	"context"
+	"database/sql"
+	"encoding/hex"
 	"errors"
 	"math/big"
+	"strings"
 
 	"github.com/hectorchu/gonano/rpc"
 	"github.com/hectorchu/gonano/wallet"
@@ -13,6 +17,11 @@ type Swap struct {
 	c           *Chain
 	hash        rpc.BlockHash
 	left, right SwapLeg
+	remaining   *big.Int
+	fills       []SwapFill
+	expiry      Expiry
+	offer       bool
+	nano        bool
 	inactive    bool
 }
 
@@ -23,6 +32,22 @@ type SwapLeg struct {
 	Amount  *big.Int
 }
 
+// SwapFill represents a fill of an offer, in which an account received an
+// amount of the offered token and paid an amount of the wanted token.
+type SwapFill struct {
+	Hash    rpc.BlockHash
+	Account string
+	Amount  *big.Int
+	Paid    *big.Int
+}
+
+// Expiry represents the expiry of a swap. It is reached once the chain
+// reaches the height, so that every parser agrees on it. A zero height
+// means no expiry.
+type Expiry struct {
+	Height uint32
+}
+
 // Hash returns the block hash of the swap.
 func (s *Swap) Hash() rpc.BlockHash {
 	return s.hash
@@ -51,41 +76,106 @@ func (s *Swap) Active() bool {
 	return !s.inactive
 }
 
+// Offer returns whether the swap is an open offer. The right leg of an
+// offer names the wanted token and minimum amount but no account.
+func (s *Swap) Offer() bool {
+	return s.offer
+}
+
+// Nano returns whether the swap is for Nano. The right leg of a swap for
+// Nano names the raw amount to be paid but no token.
+func (s *Swap) Nano() bool {
+	return s.nano
+}
+
+// Remaining returns the amount of the left leg that is yet to be settled.
+func (s *Swap) Remaining() *big.Int {
+	if s.inactive {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(s.remaining)
+}
+
+// Fills returns the fill history of an offer.
+func (s *Swap) Fills() (fills []SwapFill) {
+	for _, f := range s.fills {
+		f.Amount = new(big.Int).Set(f.Amount)
+		f.Paid = new(big.Int).Set(f.Paid)
+		fills = append(fills, f)
+	}
+	return
+}
+
+// Expiry returns the expiry of the swap.
+func (s *Swap) Expiry() Expiry {
+	return s.expiry
+}
+
+func (e Expiry) expired(height uint32) bool {
+	return e.Height != 0 && height >= e.Height
+}
+
+func (s *Swap) checkExpiry() (err error) {
+	if s.expiry.expired(s.c.now()) {
+		err = errors.New("Swap has expired")
+	}
+	return
+}
+
 // ProposeSwap proposes a swap on-chain.
 func ProposeSwap(c *Chain, a *wallet.Account, counterparty string, t *Token, amount *big.Int) (s *Swap, err error) {
-	if err = c.Parse(); err != nil {
+	return ProposeSwapWithExpiry(c, a, counterparty, t, amount, Expiry{})
+}
+
+// ProposeSwapContext is like ProposeSwap but stops when ctx is done.
+func ProposeSwapContext(ctx context.Context, c *Chain, a *wallet.Account, counterparty string, t *Token, amount *big.Int) (s *Swap, err error) {
+	return ProposeSwapWithExpiryContext(ctx, c, a, counterparty, t, amount, Expiry{})
+}
+
+// ProposeSwapWithExpiry proposes a swap on-chain that expires at the expiry.
+func ProposeSwapWithExpiry(c *Chain, a *wallet.Account, counterparty string, t *Token, amount *big.Int, expiry Expiry) (s *Swap, err error) {
+	return ProposeSwapWithExpiryContext(context.Background(), c, a, counterparty, t, amount, expiry)
+}
+
+// ProposeSwapWithExpiryContext is like ProposeSwapWithExpiry but stops when
+// ctx is done.
+func ProposeSwapWithExpiryContext(ctx context.Context, c *Chain, a *wallet.Account, counterparty string, t *Token, amount *big.Int, expiry Expiry) (s *Swap, err error) {
+	if err = c.ParseContext(ctx); err != nil {
 		return
 	}
 	if err = t.checkBalance(a.Address(), amount); err != nil {
 		return
 	}
-	height, err := c.getHeight(t.hash)
-	if err != nil {
+	if err = t.checkRestrictions(a.Address()); err != nil {
 		return
 	}
-	hash, err := c.send(a, &counterparty, &swapProposeMessage{
-		token:  height,
-		amount: amount,
-	})
+	m := &swapProposeMessage{
+		expiryHeight: expiry.Height,
+		amount:       amount,
+	}
+	if m.token, err = c.getHeightContext(ctx, t.hash); err != nil {
+		return
+	}
+	hash, err := c.sendContext(ctx, a, []string{counterparty}, m)
 	if err != nil {
 		return
 	}
 	return c.Swap(hash)
 }
 
-func (m *swapProposeMessage) process(c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+func (m *swapProposeMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
 	t, ok := c.tokens[m.token]
 	if !ok {
 		return
 	}
-	if t.checkBalance(info.BlockAccount, m.amount) != nil {
+	if t.checkBalance(info.BlockAccount, m.amount) != nil || t.checkRestrictions(info.BlockAccount) != nil {
 		return
 	}
-	destination, valid, err := c.getDestination(info.Contents)
+	destination, valid, err := c.getDestination(ctx, info.Contents)
 	if !valid {
 		return
 	}
-	c.swaps[height] = &Swap{
+	s := &Swap{
 		c:    c,
 		hash: hash,
 		left: SwapLeg{
@@ -96,27 +186,39 @@ func (m *swapProposeMessage) process(c *Chain, hash rpc.BlockHash, height uint32
 		right: SwapLeg{
 			Account: destination,
 		},
+		remaining: m.amount,
+		expiry:    Expiry{Height: m.expiryHeight},
 	}
+	c.swaps[height] = s
+	c.emit(&SwapProposed{s.event(hash, height, info.BlockAccount)})
 	return
 }
 
 // Accept accepts a swap proposal.
 func (s *Swap) Accept(a *wallet.Account, t *Token, amount *big.Int) (hash rpc.BlockHash, err error) {
-	if err = s.c.Parse(); err != nil {
+	return s.AcceptContext(context.Background(), a, t, amount)
+}
+
+// AcceptContext is like Accept but stops when ctx is done.
+func (s *Swap) AcceptContext(ctx context.Context, a *wallet.Account, t *Token, amount *big.Int) (hash rpc.BlockHash, err error) {
+	if err = s.c.ParseContext(ctx); err != nil {
 		return
 	}
 	if err = s.checkAccept(a.Address(), t, amount); err != nil {
 		return
 	}
-	swap, err := s.c.getHeight(s.hash)
+	if err = s.checkExpiry(); err != nil {
+		return
+	}
+	swap, err := s.c.getHeightContext(ctx, s.hash)
 	if err != nil {
 		return
 	}
-	token, err := t.c.getHeight(t.hash)
+	token, err := t.c.getHeightContext(ctx, t.hash)
 	if err != nil {
 		return
 	}
-	return s.c.send(a, nil, &swapAcceptMessage{
+	return s.c.sendContext(ctx, a, nil, &swapAcceptMessage{
 		swap:   swap,
 		token:  token,
 		amount: amount,
@@ -127,6 +229,12 @@ func (s *Swap) checkAccept(account string, t *Token, amount *big.Int) (err error
 	if s.inactive {
 		return errors.New("Swap is inactive")
 	}
+	if s.offer {
+		return errors.New("Swap is an offer")
+	}
+	if s.nano {
+		return errors.New("Swap is for Nano")
+	}
 	if s.right.Token != nil {
 		return errors.New("Swap already accepted")
 	}
@@ -136,10 +244,13 @@ func (s *Swap) checkAccept(account string, t *Token, amount *big.Int) (err error
 	if s.c != t.c {
 		return errors.New("Chain mismatch")
 	}
-	return t.checkBalance(account, amount)
+	if err = t.checkBalance(account, amount); err != nil {
+		return
+	}
+	return t.checkRestrictions(account)
 }
 
-func (m *swapAcceptMessage) process(c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+func (m *swapAcceptMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
 	s, ok := c.swaps[m.swap]
 	if !ok {
 		return
@@ -156,28 +267,43 @@ func (m *swapAcceptMessage) process(c *Chain, hash rpc.BlockHash, height uint32,
 		Token:   t,
 		Amount:  m.amount,
 	}
+	c.emit(&SwapAccepted{s.event(hash, height, info.BlockAccount)})
 	return true, nil
 }
 
 // Confirm confirms a swap proposal.
 func (s *Swap) Confirm(a *wallet.Account) (hash rpc.BlockHash, err error) {
-	if err = s.c.Parse(); err != nil {
+	return s.ConfirmContext(context.Background(), a)
+}
+
+// ConfirmContext is like Confirm but stops when ctx is done.
+func (s *Swap) ConfirmContext(ctx context.Context, a *wallet.Account) (hash rpc.BlockHash, err error) {
+	if err = s.c.ParseContext(ctx); err != nil {
 		return
 	}
 	if err = s.checkConfirm(a.Address()); err != nil {
 		return
 	}
-	height, err := s.c.getHeight(s.hash)
+	if err = s.checkExpiry(); err != nil {
+		return
+	}
+	height, err := s.c.getHeightContext(ctx, s.hash)
 	if err != nil {
 		return
 	}
-	return s.c.send(a, nil, &swapConfirmMessage{swap: height})
+	return s.c.sendContext(ctx, a, nil, &swapConfirmMessage{swap: height})
 }
 
 func (s *Swap) checkConfirm(account string) (err error) {
 	if s.inactive {
 		return errors.New("Swap is inactive")
 	}
+	if s.offer {
+		return errors.New("Swap is an offer")
+	}
+	if s.nano {
+		return errors.New("Swap is for Nano")
+	}
 	if s.right.Token == nil {
 		return errors.New("Swap not accepted")
 	}
@@ -190,10 +316,13 @@ func (s *Swap) checkConfirm(account string) (err error) {
 	if err = s.right.Token.checkBalance(s.right.Account, s.right.Amount); err != nil {
 		return
 	}
-	return
+	if err = s.left.Token.checkRestrictions(s.left.Account, s.right.Account); err != nil {
+		return
+	}
+	return s.right.Token.checkRestrictions(s.right.Account, s.left.Account)
 }
 
-func (m *swapConfirmMessage) process(c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+func (m *swapConfirmMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
 	s, ok := c.swaps[m.swap]
 	if !ok {
 		return
@@ -201,6 +330,13 @@ func (m *swapConfirmMessage) process(c *Chain, hash rpc.BlockHash, height uint32
 	if s.checkConfirm(info.BlockAccount) != nil {
 		return
 	}
+	s.settle()
+	delete(c.swaps, m.swap)
+	c.emit(&SwapConfirmed{s.event(hash, height, info.BlockAccount)})
+	return true, nil
+}
+
+func (s *Swap) settle() {
 	balance := s.left.Token.Balance(s.left.Account)
 	s.left.Token.setBalance(s.left.Account, balance.Sub(balance, s.left.Amount))
 	balance = s.left.Token.Balance(s.right.Account)
@@ -209,37 +345,53 @@ func (m *swapConfirmMessage) process(c *Chain, hash rpc.BlockHash, height uint32
 	s.right.Token.setBalance(s.right.Account, balance.Sub(balance, s.right.Amount))
 	balance = s.right.Token.Balance(s.left.Account)
 	s.right.Token.setBalance(s.left.Account, balance.Add(balance, s.right.Amount))
+	s.remaining = new(big.Int)
 	s.inactive = true
-	delete(c.swaps, m.swap)
-	return true, nil
 }
 
 // Cancel cancels a swap proposal.
 func (s *Swap) Cancel(a *wallet.Account) (hash rpc.BlockHash, err error) {
-	if err = s.c.Parse(); err != nil {
+	return s.CancelContext(context.Background(), a)
+}
+
+// CancelContext is like Cancel but stops when ctx is done.
+func (s *Swap) CancelContext(ctx context.Context, a *wallet.Account) (hash rpc.BlockHash, err error) {
+	if err = s.c.ParseContext(ctx); err != nil {
 		return
 	}
 	if err = s.checkCancel(a.Address()); err != nil {
 		return
 	}
-	height, err := s.c.getHeight(s.hash)
+	height, err := s.c.getHeightContext(ctx, s.hash)
 	if err != nil {
 		return
 	}
-	return s.c.send(a, nil, &swapCancelMessage{swap: height})
+	return s.c.sendContext(ctx, a, nil, &swapCancelMessage{swap: height})
 }
 
 func (s *Swap) checkCancel(account string) (err error) {
 	if s.inactive {
 		return errors.New("Swap is inactive")
 	}
+	if s.nano && account != s.right.Account {
+		return errors.New("Must cancel swap for Nano with right account")
+	}
 	if account != s.left.Account && account != s.right.Account {
 		return errors.New("Must cancel swap with left or right account")
 	}
 	return
 }
 
-func (m *swapCancelMessage) process(c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+// cancel deactivates the swap, returning any escrowed tokens.
+func (s *Swap) cancel() {
+	if s.nano {
+		balance := s.left.Token.Balance(s.left.Account)
+		s.left.Token.setBalance(s.left.Account, balance.Add(balance, s.remaining))
+	}
+	s.inactive = true
+}
+
+func (m *swapCancelMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
 	s, ok := c.swaps[m.swap]
 	if !ok {
 		return
@@ -247,7 +399,128 @@ func (m *swapCancelMessage) process(c *Chain, hash rpc.BlockHash, height uint32,
 	if s.checkCancel(info.BlockAccount) != nil {
 		return
 	}
-	s.inactive = true
+	s.cancel()
 	delete(c.swaps, m.swap)
+	c.emit(&SwapCancelled{s.event(hash, height, info.BlockAccount)})
 	return true, nil
 }
+
+func (s *Swap) loadState(db *sql.DB) (err error) {
+	var (
+		hash                    = strings.ToUpper(hex.EncodeToString(s.hash))
+		leftToken, leftAmount   string
+		rightToken, rightAmount sql.NullString
+		remaining               string
+		active, ok              bool
+	)
+	row := db.QueryRow(`
+		SELECT left_account, left_token, left_amount, right_account, right_token, right_amount,
+		remaining, expiry_height, offer, nano, active
+		FROM swaps WHERE hash = ?
+	`, hash)
+	if err = row.Scan(
+		&s.left.Account, &leftToken, &leftAmount,
+		&s.right.Account, &rightToken, &rightAmount,
+		&remaining, &s.expiry.Height, &s.offer, &s.nano, &active,
+	); err != nil {
+		return
+	}
+	s.inactive = !active
+	if s.left.Token, s.left.Amount, err = s.loadLeg(leftToken, leftAmount); err != nil {
+		return
+	}
+	if rightToken.Valid {
+		if s.right.Token, s.right.Amount, err = s.loadLeg(rightToken.String, rightAmount.String); err != nil {
+			return
+		}
+	} else if rightAmount.Valid {
+		if s.right.Amount, ok = new(big.Int).SetString(rightAmount.String, 10); !ok {
+			return errors.New("Failed to parse amount from DB")
+		}
+	}
+	if s.remaining, ok = new(big.Int).SetString(remaining, 10); !ok {
+		return errors.New("Failed to parse amount from DB")
+	}
+	return s.loadFills(db, hash)
+}
+
+func (s *Swap) loadFills(db *sql.DB, hash string) (err error) {
+	rows, err := db.Query("SELECT hash, account, amount, paid FROM swap_fills WHERE swap = ? ORDER BY seq", hash)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			f                      SwapFill
+			fillHash, amount, paid string
+			ok1, ok2               bool
+		)
+		if err = rows.Scan(&fillHash, &f.Account, &amount, &paid); err != nil {
+			return
+		}
+		if f.Hash, err = hex.DecodeString(fillHash); err != nil {
+			return
+		}
+		f.Amount, ok1 = new(big.Int).SetString(amount, 10)
+		f.Paid, ok2 = new(big.Int).SetString(paid, 10)
+		if !ok1 || !ok2 {
+			return errors.New("Failed to parse amount from DB")
+		}
+		s.fills = append(s.fills, f)
+	}
+	return rows.Err()
+}
+
+func (s *Swap) loadLeg(tokenStr, amountStr string) (t *Token, amount *big.Int, err error) {
+	hash, err := hex.DecodeString(tokenStr)
+	if err != nil {
+		return
+	}
+	if t, err = s.c.Token(hash); err != nil {
+		return
+	}
+	amount, ok := new(big.Int).SetString(amountStr, 10)
+	if !ok {
+		err = errors.New("Failed to parse amount from DB")
+	}
+	return
+}
+
+func (s *Swap) saveState(tx *sql.Tx, height uint32) (err error) {
+	var rightToken, rightAmount interface{}
+	if s.right.Token != nil {
+		rightToken = strings.ToUpper(hex.EncodeToString(s.right.Token.hash))
+	}
+	if s.right.Amount != nil {
+		rightAmount = s.right.Amount.String()
+	}
+	_, err = tx.Exec(`
+		REPLACE INTO swaps
+		(hash, chain, height, left_account, left_token, left_amount, right_account, right_token, right_amount,
+		remaining, expiry_height, offer, nano, active)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		strings.ToUpper(hex.EncodeToString(s.hash)), s.c.Address(), height,
+		s.left.Account, strings.ToUpper(hex.EncodeToString(s.left.Token.hash)), s.left.Amount.String(),
+		s.right.Account, rightToken, rightAmount,
+		s.remaining.String(), s.expiry.Height, s.offer, s.nano, !s.inactive,
+	)
+	if err != nil {
+		return
+	}
+	stmt, err := tx.Prepare("REPLACE INTO swap_fills (swap, seq, hash, account, amount, paid) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	for i, f := range s.fills {
+		if _, err = stmt.Exec(
+			strings.ToUpper(hex.EncodeToString(s.hash)), i,
+			strings.ToUpper(hex.EncodeToString(f.Hash)), f.Account, f.Amount.String(), f.Paid.String(),
+		); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -41,3 +41,291 @@ func TestSwap(t *testing.T) {
 	assert.Equal(t, amount2, token2.Balance(getAccount(0).Address()))
 	assertEqualChain(t, chain, loadChain(t, chain.Address()))
 }
+
+func TestSwapState(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token1  = genesis(t, chain, getAccount(0))
+		token2  = genesis(t, chain, getAccount(1))
+		amount1 = big.NewInt(1000)
+		amount2 = big.NewInt(2000)
+	)
+	swap, err := tokenchain.ProposeSwap(chain, getAccount(0), getAccount(1).Address(), token1, amount1)
+	require.Nil(t, err)
+	chain2 := restoreChain(t, chain)
+	swap2, err := chain2.Swap(swap.Hash())
+	require.Nil(t, err)
+	assert.True(t, swap2.Active())
+	assert.Equal(t, swap.Left().Account, swap2.Left().Account)
+	assert.Equal(t, swap.Left().Token.Hash(), swap2.Left().Token.Hash())
+	assert.Equal(t, swap.Left().Amount, swap2.Left().Amount)
+	assert.Equal(t, swap.Right().Account, swap2.Right().Account)
+	assert.Nil(t, swap2.Right().Token)
+	_, err = swap.Accept(getAccount(1), token2, amount2)
+	require.Nil(t, err)
+	_, err = swap.Confirm(getAccount(0))
+	require.Nil(t, err)
+	err = chain2.Parse()
+	require.Nil(t, err)
+	_, err = chain2.Swap(swap.Hash())
+	assert.NotNil(t, err)
+	assertEqualChain(t, chain, chain2)
+	assertEqualChain(t, chain2, loadChain(t, chain.Address()))
+}
+
+func TestSwapExpiry(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token1  = genesis(t, chain, getAccount(0))
+		token2  = genesis(t, chain, getAccount(1))
+		amount1 = big.NewInt(1000)
+		amount2 = big.NewInt(2000)
+	)
+	height, err := chain.BlockHeight(token2.Hash())
+	require.Nil(t, err)
+	swap, err := tokenchain.ProposeSwapWithExpiry(chain, getAccount(0), getAccount(1).Address(), token1, amount1, tokenchain.Expiry{
+		Height: height + 2,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, height+2, swap.Expiry().Height)
+	_, err = swap.Accept(getAccount(1), token2, amount2)
+	assert.NotNil(t, err)
+	chain2 := restoreChain(t, chain)
+	swap2, err := chain2.Swap(swap.Hash())
+	require.Nil(t, err)
+	_, err = swap2.Accept(getAccount(1), token2, amount2)
+	assert.NotNil(t, err)
+	_, err = token1.Transfer(getAccount(0), getAccount(1).Address(), amount1)
+	require.Nil(t, err)
+	assert.False(t, swap.Active())
+	_, err = chain.Swap(swap.Hash())
+	assert.NotNil(t, err)
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
+
+func TestOffer(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token1  = genesis(t, chain, getAccount(0))
+		token2  = genesis(t, chain, getAccount(1))
+		amount1 = big.NewInt(1000)
+		amount2 = big.NewInt(2000)
+	)
+	offer, err := tokenchain.ProposeOffer(chain, getAccount(0), token1, amount1, token2, amount2, tokenchain.Expiry{})
+	require.Nil(t, err)
+	assert.True(t, offer.Offer())
+	assert.Equal(t, getAccount(0).Address(), offer.Left().Account)
+	assert.Empty(t, offer.Right().Account)
+	assert.Equal(t, token2, offer.Right().Token)
+	assert.Equal(t, amount2, offer.Right().Amount)
+	assert.Contains(t, chain.Offers(), string(offer.Hash()))
+	_, err = offer.Accept(getAccount(1), token2, amount2)
+	assert.NotNil(t, err)
+	_, err = offer.Fill(getAccount(1), new(big.Int).Sub(amount2, big.NewInt(1)))
+	assert.NotNil(t, err)
+	amount3 := big.NewInt(2500)
+	_, err = offer.Fill(getAccount(1), amount3)
+	require.Nil(t, err)
+	assert.False(t, offer.Active())
+	assert.Empty(t, offer.Right().Account)
+	fills := offer.Fills()
+	require.Len(t, fills, 1)
+	assert.Equal(t, getAccount(1).Address(), fills[0].Account)
+	assert.Equal(t, amount1, fills[0].Amount)
+	assert.Equal(t, amount3, fills[0].Paid)
+	assert.Empty(t, chain.Offers())
+	assert.Equal(t, new(big.Int).Sub(supply, amount1), token1.Balance(getAccount(0).Address()))
+	assert.Equal(t, amount1, token1.Balance(getAccount(1).Address()))
+	assert.Equal(t, new(big.Int).Sub(supply, amount3), token2.Balance(getAccount(1).Address()))
+	assert.Equal(t, amount3, token2.Balance(getAccount(0).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
+
+func TestOfferState(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token1  = genesis(t, chain, getAccount(0))
+		token2  = genesis(t, chain, getAccount(1))
+		amount1 = big.NewInt(1000)
+		amount2 = big.NewInt(2000)
+	)
+	offer, err := tokenchain.ProposeOffer(chain, getAccount(0), token1, amount1, token2, amount2, tokenchain.Expiry{})
+	require.Nil(t, err)
+	chain2 := restoreChain(t, chain)
+	offers := chain2.Offers()
+	require.Contains(t, offers, string(offer.Hash()))
+	offer2 := offers[string(offer.Hash())]
+	assert.True(t, offer2.Offer())
+	assert.Equal(t, offer.Left().Amount, offer2.Left().Amount)
+	assert.Equal(t, offer.Right().Token.Hash(), offer2.Right().Token.Hash())
+	assert.Equal(t, offer.Right().Amount, offer2.Right().Amount)
+	_, err = offer.Fill(getAccount(1), amount2)
+	require.Nil(t, err)
+	err = chain2.Parse()
+	require.Nil(t, err)
+	assert.Empty(t, chain2.Offers())
+	assertEqualChain(t, chain, chain2)
+}
+
+func TestOfferZeroAmount(t *testing.T) {
+	var (
+		chain  = newChain(t)
+		token1 = genesis(t, chain, getAccount(0))
+		token2 = genesis(t, chain, getAccount(1))
+		amount = big.NewInt(1000)
+	)
+	_, err := tokenchain.ProposeOffer(chain, getAccount(0), token1, new(big.Int), token2, amount, tokenchain.Expiry{})
+	assert.NotNil(t, err)
+	hash, err := tokenchain.SendOffer(chain, getAccount(0), token1, new(big.Int), token2, amount)
+	require.Nil(t, err)
+	assert.Empty(t, chain.Offers())
+	_, err = tokenchain.SendFill(chain, getAccount(1), hash, amount)
+	require.Nil(t, err)
+	assert.Equal(t, supply, token2.Balance(getAccount(1).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
+
+func TestPartialFill(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token1  = genesis(t, chain, getAccount(0))
+		token2  = genesis(t, chain, getAccount(1))
+		amount1 = big.NewInt(1000)
+		amount2 = big.NewInt(2001)
+	)
+	offer, err := tokenchain.ProposeOffer(chain, getAccount(0), token1, amount1, token2, amount2, tokenchain.Expiry{})
+	require.Nil(t, err)
+	assert.Equal(t, amount1, offer.Remaining())
+	_, err = offer.FillPartial(getAccount(1), big.NewInt(1001))
+	assert.NotNil(t, err)
+	_, err = offer.FillPartial(getAccount(1), big.NewInt(3))
+	require.Nil(t, err)
+	assert.True(t, offer.Active())
+	assert.Equal(t, big.NewInt(997), offer.Remaining())
+	fills := offer.Fills()
+	require.Len(t, fills, 1)
+	assert.Equal(t, big.NewInt(3), fills[0].Amount)
+	assert.Equal(t, big.NewInt(7), fills[0].Paid)
+	assertEqualChain(t, chain, restoreChain(t, chain))
+	_, err = offer.Fill(getAccount(1), big.NewInt(1993))
+	assert.NotNil(t, err)
+	_, err = offer.Fill(getAccount(1), big.NewInt(1994))
+	require.Nil(t, err)
+	assert.False(t, offer.Active())
+	assert.Zero(t, offer.Remaining().Sign())
+	require.Len(t, offer.Fills(), 2)
+	assert.Equal(t, new(big.Int).Sub(supply, amount1), token1.Balance(getAccount(0).Address()))
+	assert.Equal(t, amount1, token1.Balance(getAccount(1).Address()))
+	assert.Equal(t, amount2, token2.Balance(getAccount(0).Address()))
+	assert.Equal(t, new(big.Int).Sub(supply, amount2), token2.Balance(getAccount(1).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+}
+
+func TestPartialFillRounding(t *testing.T) {
+	var (
+		chain   = newChain(t)
+		token1  = genesis(t, chain, getAccount(0))
+		token2  = genesis(t, chain, getAccount(1))
+		amount1 = big.NewInt(1000)
+		amount2 = big.NewInt(2001)
+		paid    = new(big.Int)
+	)
+	offer, err := tokenchain.ProposeOffer(chain, getAccount(0), token1, amount1, token2, amount2, tokenchain.Expiry{})
+	require.Nil(t, err)
+	_, err = offer.FillPartial(getAccount(1), new(big.Int))
+	assert.NotNil(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = offer.FillPartial(getAccount(1), big.NewInt(3))
+		require.Nil(t, err)
+	}
+	for _, fill := range offer.Fills() {
+		paid.Add(paid, fill.Paid)
+	}
+	assert.Equal(t, big.NewInt(31), paid)
+	_, err = offer.FillPartial(getAccount(1), offer.Remaining())
+	require.Nil(t, err)
+	assert.False(t, offer.Active())
+	fills := offer.Fills()
+	require.Len(t, fills, 6)
+	paid.Add(paid, fills[5].Paid)
+	assert.Equal(t, amount2, paid)
+	assert.Equal(t, amount2, token2.Balance(getAccount(0).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
+
+func TestSwapForNano(t *testing.T) {
+	var (
+		chain  = newChain(t)
+		token  = genesis(t, chain, getAccount(0))
+		amount = big.NewInt(1000)
+		raw    = big.NewInt(1e6)
+	)
+	swap, err := tokenchain.ProposeSwapForNano(chain, getAccount(0), getAccount(1).Address(), token, amount, raw, tokenchain.Expiry{Height: 1 << 30})
+	require.Nil(t, err)
+	assert.True(t, swap.Nano())
+	assert.Nil(t, swap.Right().Token)
+	assert.Equal(t, raw, swap.Right().Amount)
+	assert.Equal(t, new(big.Int).Sub(supply, amount), token.Balance(getAccount(0).Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+	_, err = swap.Cancel(getAccount(0))
+	assert.NotNil(t, err)
+	_, err = swap.Accept(getAccount(1), token, amount)
+	assert.NotNil(t, err)
+	_, err = swap.Pay(getAccount(1))
+	require.Nil(t, err)
+	assert.False(t, swap.Active())
+	assert.Equal(t, new(big.Int).Sub(supply, amount), token.Balance(getAccount(0).Address()))
+	assert.Equal(t, amount, token.Balance(getAccount(1).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
+
+func TestSwapForNanoExpiry(t *testing.T) {
+	var (
+		chain  = newChain(t)
+		token  = genesis(t, chain, getAccount(0))
+		amount = big.NewInt(1000)
+	)
+	height, err := chain.BlockHeight(token.Hash())
+	require.Nil(t, err)
+	_, err = tokenchain.ProposeSwapForNano(chain, getAccount(0), getAccount(1).Address(), token, amount, big.NewInt(1e6), tokenchain.Expiry{})
+	assert.NotNil(t, err)
+	swap, err := tokenchain.ProposeSwapForNano(chain, getAccount(0), getAccount(1).Address(), token, amount, big.NewInt(1e6), tokenchain.Expiry{Height: height + 2})
+	require.Nil(t, err)
+	_, err = swap.Pay(getAccount(1))
+	assert.NotNil(t, err)
+	_, err = token.Transfer(getAccount(0), getAccount(1).Address(), amount)
+	require.Nil(t, err)
+	assert.False(t, swap.Active())
+	assert.Equal(t, new(big.Int).Sub(supply, amount), token.Balance(getAccount(0).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+}
+
+func TestSwapForNanoPaused(t *testing.T) {
+	var (
+		chain  = newChain(t)
+		admin  = getAccount(0)
+		amount = big.NewInt(1000)
+	)
+	token, err := tokenchain.TokenGenesisWithOptions(chain, admin, "TOKEN", supply, 5, tokenchain.TokenOptions{Admin: true})
+	require.Nil(t, err)
+	swap, err := tokenchain.ProposeSwapForNano(chain, admin, getAccount(1).Address(), token, amount, big.NewInt(1e6), tokenchain.Expiry{Height: 1 << 30})
+	require.Nil(t, err)
+	height, err := chain.BlockHeight(swap.Hash())
+	require.Nil(t, err)
+	swap2, err := tokenchain.ProposeSwapForNano(chain, admin, getAccount(1).Address(), token, amount, big.NewInt(1e6), tokenchain.Expiry{Height: height + 12})
+	require.Nil(t, err)
+	_, err = swap2.Pay(getAccount(1))
+	assert.NotNil(t, err)
+	_, err = token.Pause(admin)
+	require.Nil(t, err)
+	_, err = swap.Pay(getAccount(1))
+	require.Nil(t, err)
+	assert.False(t, swap.Active())
+	assert.Equal(t, amount, token.Balance(getAccount(1).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+}
@@ -1,6 +1,7 @@
 package tokenchain
 
 import (
+	"context"
 	"database/sql"
 	"encoding/hex"
 	"errors"
@@ -13,12 +14,25 @@ import (
 
 // Token represents a token.
 type Token struct {
-	c        *Chain
-	hash     rpc.BlockHash
-	name     string
-	supply   *big.Int
-	decimals byte
-	balances map[string]*big.Int
+	c               *Chain
+	hash            rpc.BlockHash
+	issuer          string
+	owner           string
+	pendingOwner    string
+	name            string
+	supply          *big.Int
+	burned          *big.Int
+	decimals        byte
+	mintAuthority   string
+	metadata        *Metadata
+	metadataHistory []MetadataRecord
+	balances        map[string]*big.Int
+	allowances      map[string]map[string]*big.Int
+	vestings        map[string][]*vestingGrant
+	transfers       []TransferRecord
+	admin           string
+	paused          bool
+	frozen          map[string]bool
 }
 
 // Hash returns the block hash of the token.
@@ -26,6 +40,11 @@ func (t *Token) Hash() rpc.BlockHash {
 	return t.hash
 }
 
+// Issuer returns the account that issued the token.
+func (t *Token) Issuer() string {
+	return t.issuer
+}
+
 // Name returns the token name.
 func (t *Token) Name() string {
 	return t.name
@@ -36,11 +55,22 @@ func (t *Token) Supply() *big.Int {
 	return new(big.Int).Set(t.supply)
 }
 
+// Burned returns the amount of tokens burned.
+func (t *Token) Burned() *big.Int {
+	return new(big.Int).Set(t.burned)
+}
+
 // Decimals returns the token decimals.
 func (t *Token) Decimals() byte {
 	return t.decimals
 }
 
+// MintAuthority returns the account allowed to mint the token besides its
+// owner, or an empty string if the supply is fixed.
+func (t *Token) MintAuthority() string {
+	return t.mintAuthority
+}
+
 // Balances gets the token balances.
 func (t *Token) Balances() (balances map[string]*big.Int) {
 	balances = make(map[string]*big.Int)
@@ -68,21 +98,63 @@ func (t *Token) checkBalance(account string, amount *big.Int) (err error) {
 		return
 	}
 	if t.Balance(account).Cmp(amount) < 0 {
-		err = errors.New("Insufficient balance")
+		return errors.New("Insufficient balance")
+	}
+	if t.SpendableBalance(account).Cmp(amount) < 0 {
+		err = errors.New("Balance is locked")
 	}
 	return
 }
 
+// TokenOptions represents the optional features of a token chosen at genesis.
+type TokenOptions struct {
+	// MintAuthority, if set, makes the token mintable by it and the owner.
+	MintAuthority string
+	// Admin makes the issuer the admin of the token.
+	Admin bool
+}
+
 // TokenGenesis initializes a new token on a chain.
 func TokenGenesis(c *Chain, a *wallet.Account, name string, supply *big.Int, decimals byte) (t *Token, err error) {
-	if err = c.Parse(); err != nil {
+	return TokenGenesisWithOptions(c, a, name, supply, decimals, TokenOptions{})
+}
+
+// MintableTokenGenesis initializes a new token on a chain whose supply
+// can later be increased by the mint authority.
+func MintableTokenGenesis(c *Chain, a *wallet.Account, name string, supply *big.Int, decimals byte, authority string) (t *Token, err error) {
+	return TokenGenesisWithOptions(c, a, name, supply, decimals, TokenOptions{MintAuthority: authority})
+}
+
+// TokenGenesisContext is like TokenGenesis but stops when ctx is done.
+// If ctx is done after the message was sent, the token may still be
+// created once the chain is parsed.
+func TokenGenesisContext(ctx context.Context, c *Chain, a *wallet.Account, name string, supply *big.Int, decimals byte) (t *Token, err error) {
+	return tokenGenesis(ctx, c, a, name, supply, decimals, TokenOptions{})
+}
+
+// TokenGenesisWithOptions initializes a new token on a chain with optional features.
+func TokenGenesisWithOptions(c *Chain, a *wallet.Account, name string, supply *big.Int, decimals byte, opts TokenOptions) (t *Token, err error) {
+	return tokenGenesis(context.Background(), c, a, name, supply, decimals, opts)
+}
+
+func tokenGenesis(ctx context.Context, c *Chain, a *wallet.Account, name string, supply *big.Int, decimals byte, opts TokenOptions) (t *Token, err error) {
+	if err = c.ParseContext(ctx); err != nil {
 		return
 	}
 	if err = checkPositive(supply); err != nil {
 		return
 	}
-	hash, err := c.send(a, nil, &genesisMessage{
+	if (opts.MintAuthority != "" || opts.Admin) && decimals&genesisFlags != 0 {
+		return nil, errors.New("Decimals out of range")
+	}
+	var destinations []string
+	if opts.MintAuthority != "" {
+		destinations = []string{opts.MintAuthority}
+	}
+	hash, err := c.sendContext(ctx, a, destinations, &genesisMessage{
 		decimals: decimals,
+		mintable: opts.MintAuthority != "",
+		admin:    opts.Admin,
 		name:     name,
 		supply:   supply,
 	})
@@ -92,42 +164,70 @@ func TokenGenesis(c *Chain, a *wallet.Account, name string, supply *big.Int, dec
 	return c.Token(hash)
 }
 
-func (m *genesisMessage) process(c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+func (m *genesisMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
 	if err = checkPositive(m.supply); err != nil {
 		return
 	}
 	t := &Token{
-		c:        c,
-		hash:     hash,
-		name:     m.name,
-		supply:   m.supply,
-		decimals: m.decimals,
-		balances: make(map[string]*big.Int),
+		c:          c,
+		hash:       hash,
+		issuer:     info.BlockAccount,
+		owner:      info.BlockAccount,
+		name:       m.name,
+		supply:     m.supply,
+		burned:     new(big.Int),
+		decimals:   m.decimals,
+		balances:   make(map[string]*big.Int),
+		allowances: make(map[string]map[string]*big.Int),
+		vestings:   make(map[string][]*vestingGrant),
+		frozen:     make(map[string]bool),
+	}
+	if m.admin {
+		t.admin = info.BlockAccount
+	}
+	if m.mintable {
+		if t.mintAuthority, valid, err = c.getDestination(ctx, info.Contents); !valid {
+			return
+		}
 	}
 	t.setBalance(info.BlockAccount, m.supply)
 	c.tokens[height] = t
+	c.emit(&TokenCreated{
+		EventBlock: EventBlock{Hash: hash, Height: height},
+		Token:      t,
+		Issuer:     t.issuer,
+		Supply:     new(big.Int).Set(m.supply),
+	})
 	return true, nil
 }
 
 // Transfer transfers an amount of tokens to another account.
 func (t *Token) Transfer(a *wallet.Account, account string, amount *big.Int) (hash rpc.BlockHash, err error) {
-	if err = t.c.Parse(); err != nil {
+	return t.TransferContext(context.Background(), a, account, amount)
+}
+
+// TransferContext is like Transfer but stops when ctx is done.
+func (t *Token) TransferContext(ctx context.Context, a *wallet.Account, account string, amount *big.Int) (hash rpc.BlockHash, err error) {
+	if err = t.c.ParseContext(ctx); err != nil {
 		return
 	}
 	if err = t.checkBalance(a.Address(), amount); err != nil {
 		return
 	}
-	height, err := t.c.getHeight(t.hash)
+	if err = t.checkRestrictions(a.Address(), account); err != nil {
+		return
+	}
+	height, err := t.c.getHeightContext(ctx, t.hash)
 	if err != nil {
 		return
 	}
-	return t.c.send(a, &account, &transferMessage{
+	return t.c.sendContext(ctx, a, []string{account}, &transferMessage{
 		token:  height,
 		amount: amount,
 	})
 }
 
-func (m *transferMessage) process(c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+func (m *transferMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
 	t, ok := c.tokens[m.token]
 	if !ok {
 		return
@@ -135,30 +235,159 @@ func (m *transferMessage) process(c *Chain, hash rpc.BlockHash, height uint32, i
 	if t.checkBalance(info.BlockAccount, m.amount) != nil {
 		return
 	}
-	destination, valid, err := c.getDestination(info.Contents)
+	destination, valid, err := c.getDestination(ctx, info.Contents)
 	if !valid {
 		return
 	}
-	balance := t.Balance(info.BlockAccount)
-	t.setBalance(info.BlockAccount, balance.Sub(balance, m.amount))
-	balance = t.Balance(destination)
-	t.setBalance(destination, balance.Add(balance, m.amount))
+	if t.checkRestrictions(info.BlockAccount, destination) != nil {
+		return false, nil
+	}
+	t.transfer(hash, info.BlockAccount, destination, m.amount, m.memo)
 	return
 }
 
+// Mint mints an amount of new tokens to an account. A mintable token can be
+// minted by its mint authority or its owner.
+func (t *Token) Mint(a *wallet.Account, account string, amount *big.Int) (hash rpc.BlockHash, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if err = t.checkMint(a.Address(), amount); err != nil {
+		return
+	}
+	if err = t.checkRestrictions(account); err != nil {
+		return
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	return t.c.send(a, []string{account}, &mintMessage{
+		token:  height,
+		amount: amount,
+	})
+}
+
+func (t *Token) checkMint(account string, amount *big.Int) (err error) {
+	if t.mintAuthority == "" {
+		return errors.New("Token is not mintable")
+	}
+	if account != t.mintAuthority && account != t.owner {
+		return errors.New("Must mint with mint authority or owner")
+	}
+	if err = checkPositive(amount); err != nil {
+		return
+	}
+	if new(big.Int).Add(t.supply, amount).BitLen() > 128 {
+		err = errors.New("Supply overflow")
+	}
+	return
+}
+
+func (m *mintMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if t.checkMint(info.BlockAccount, m.amount) != nil {
+		return
+	}
+	destination, valid, err := c.getDestination(ctx, info.Contents)
+	if !valid {
+		return
+	}
+	if t.checkRestrictions(destination) != nil {
+		return false, nil
+	}
+	t.mint(destination, m.amount)
+	return
+}
+
+func (t *Token) mint(account string, amount *big.Int) {
+	t.supply = new(big.Int).Add(t.supply, amount)
+	balance := t.Balance(account)
+	t.setBalance(account, balance.Add(balance, amount))
+}
+
+// Burn destroys an amount of tokens, reducing the supply.
+func (t *Token) Burn(a *wallet.Account, amount *big.Int) (hash rpc.BlockHash, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if err = t.checkBalance(a.Address(), amount); err != nil {
+		return
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	return t.c.send(a, nil, &burnMessage{
+		token:  height,
+		amount: amount,
+	})
+}
+
+func (m *burnMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if t.checkBalance(info.BlockAccount, m.amount) != nil {
+		return
+	}
+	t.burn(info.BlockAccount, m.amount)
+	return true, nil
+}
+
+func (t *Token) burn(account string, amount *big.Int) {
+	balance := t.Balance(account)
+	t.setBalance(account, balance.Sub(balance, amount))
+	t.supply = new(big.Int).Sub(t.supply, amount)
+	t.burned = new(big.Int).Add(t.burned, amount)
+}
+
 func (t *Token) loadState(db *sql.DB) (err error) {
 	var (
-		hash   = strings.ToUpper(hex.EncodeToString(t.hash))
-		supply string
-		ok     bool
+		hash           = strings.ToUpper(hex.EncodeToString(t.hash))
+		supply, burned string
+		ok             bool
 	)
-	row := db.QueryRow("SELECT name, supply, decimals FROM tokens WHERE hash = ?", hash)
-	if err = row.Scan(&t.name, &supply, &t.decimals); err != nil {
+	row := db.QueryRow(`
+		SELECT issuer, owner, pending_owner, name, supply, burned, decimals, mint_authority
+		FROM tokens WHERE hash = ?
+	`, hash)
+	if err = row.Scan(&t.issuer, &t.owner, &t.pendingOwner, &t.name, &supply, &burned, &t.decimals, &t.mintAuthority); err != nil {
 		return
 	}
+	if t.issuer == "" {
+		node := t.c.backend(context.Background())
+		info, err := node.BlockInfo(t.hash)
+		if err != nil {
+			return err
+		}
+		if info, err = node.BlockInfo(info.Contents.Link); err != nil {
+			return err
+		}
+		t.issuer = info.BlockAccount
+	}
+	if t.owner == "" {
+		t.owner = t.issuer
+	}
 	if t.supply, ok = new(big.Int).SetString(supply, 10); !ok {
 		return errors.New("Failed to parse supply from DB")
 	}
+	if t.burned, ok = new(big.Int).SetString(burned, 10); !ok {
+		return errors.New("Failed to parse burned from DB")
+	}
+	md := new(Metadata)
+	row = db.QueryRow("SELECT name, symbol, description, uri FROM token_metadata WHERE hash = ?", hash)
+	switch err = row.Scan(&md.Name, &md.Symbol, &md.Description, &md.URI); err {
+	case nil:
+		t.metadata = md
+	case sql.ErrNoRows:
+	default:
+		return
+	}
 	t.balances = make(map[string]*big.Int)
 	rows, err := db.Query("SELECT account, balance FROM token_balances WHERE hash = ?", hash)
 	if err != nil {
@@ -176,29 +405,59 @@ func (t *Token) loadState(db *sql.DB) (err error) {
 		}
 		t.balances[account] = balance
 	}
-	return rows.Err()
-}
-
-func (t *Token) saveState(tx *sql.Tx, height uint32) (err error) {
-	if _, err = tx.Exec(`
-		CREATE TABLE IF NOT EXISTS tokens
-		(hash TEXT PRIMARY KEY, chain TEXT, height INTEGER, name TEXT, supply TEXT, decimals INTEGER)
-	`); err != nil {
+	if err = rows.Err(); err != nil {
+		return
+	}
+	t.allowances = make(map[string]map[string]*big.Int)
+	rows, err = db.Query("SELECT owner, spender, allowance FROM token_allowances WHERE hash = ?", hash)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var owner, spender, allowanceStr string
+		if err = rows.Scan(&owner, &spender, &allowanceStr); err != nil {
+			return
+		}
+		allowance, ok := new(big.Int).SetString(allowanceStr, 10)
+		if !ok {
+			return errors.New("Failed to parse allowance from DB")
+		}
+		t.setAllowance(owner, spender, allowance)
+	}
+	if err = rows.Err(); err != nil {
 		return
 	}
-	if _, err = tx.Exec(`
-		CREATE TABLE IF NOT EXISTS token_balances
-		(hash TEXT, account TEXT, balance TEXT, PRIMARY KEY (hash, account))
-	`); err != nil {
+	if err = t.loadVestings(db, hash); err != nil {
 		return
 	}
+	if err = t.loadTransfers(db, hash); err != nil {
+		return
+	}
+	if err = t.loadMetadataHistory(db, hash); err != nil {
+		return
+	}
+	return t.loadGovernance(db, hash)
+}
+
+func (t *Token) saveState(tx *sql.Tx, height uint32) (err error) {
 	hash := strings.ToUpper(hex.EncodeToString(t.hash))
 	if _, err = tx.Exec(
-		"REPLACE INTO tokens (hash, chain, height, name, supply, decimals) VALUES (?, ?, ?, ?, ?, ?)",
-		hash, t.c.Address(), height, t.name, t.supply.String(), t.decimals,
+		`REPLACE INTO tokens (hash, chain, height, issuer, owner, pending_owner, name, supply, burned, decimals, mint_authority)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		hash, t.c.Address(), height, t.issuer, t.owner, t.pendingOwner, t.name,
+		t.supply.String(), t.burned.String(), t.decimals, t.mintAuthority,
 	); err != nil {
 		return
 	}
+	if t.metadata != nil {
+		if _, err = tx.Exec(
+			"REPLACE INTO token_metadata (hash, name, symbol, description, uri) VALUES (?, ?, ?, ?, ?)",
+			hash, t.metadata.Name, t.metadata.Symbol, t.metadata.Description, t.metadata.URI,
+		); err != nil {
+			return
+		}
+	}
 	stmt, err := tx.Prepare("REPLACE INTO token_balances (hash, account, balance) VALUES (?, ?, ?)")
 	if err != nil {
 		return
@@ -209,5 +468,26 @@ func (t *Token) saveState(tx *sql.Tx, height uint32) (err error) {
 			return
 		}
 	}
-	return
+	stmt2, err := tx.Prepare("REPLACE INTO token_allowances (hash, owner, spender, allowance) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+	defer stmt2.Close()
+	for owner, allowances := range t.allowances {
+		for spender, allowance := range allowances {
+			if _, err = stmt2.Exec(hash, owner, spender, allowance.String()); err != nil {
+				return
+			}
+		}
+	}
+	if err = t.saveVestings(tx, hash); err != nil {
+		return
+	}
+	if err = t.saveTransfers(tx, hash); err != nil {
+		return
+	}
+	if err = t.saveMetadataHistory(tx, hash); err != nil {
+		return
+	}
+	return t.saveGovernance(tx, hash)
 }
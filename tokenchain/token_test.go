@@ -1,23 +1,49 @@
 package tokenchain_test
 
 import (
+	"database/sql"
 	"encoding/hex"
 	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hectorchu/gonano/wallet"
 	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/hectorchu/nano-token-protocol/tokenchain/nanosim"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-const rpcURL = "https://mynano.ninja/api/node"
+var (
+	sim    *nanosim.Server
+	rpcURL string
+)
 
-func getAccount(i int) (a *wallet.Account) {
-	seeds := []string{
-		"52fdfc072182654f163f5f0f9a621d729566c74d10037c4d7bbb0407d1e2c649",
-		"dfaf7d4eba814bcb3a9926011d83e3fda34b8e11e635b3834a3e3cb5279a941e",
+var seeds = []string{
+	"52fdfc072182654f163f5f0f9a621d729566c74d10037c4d7bbb0407d1e2c649",
+	"dfaf7d4eba814bcb3a9926011d83e3fda34b8e11e635b3834a3e3cb5279a941e",
+}
+
+func TestMain(m *testing.M) {
+	sim = nanosim.NewServer()
+	rpcURL = sim.URL
+	for i := range seeds {
+		a := getAccount(i)
+		if _, err := sim.Fund(a.Address(), big.NewInt(1e12)); err != nil {
+			panic(err)
+		}
+		if err := a.ReceivePendings(); err != nil {
+			panic(err)
+		}
 	}
+	code := m.Run()
+	sim.Close()
+	os.Exit(code)
+}
+
+func getAccount(i int) (a *wallet.Account) {
 	seed, _ := hex.DecodeString(seeds[i])
 	w, _ := wallet.NewWallet(seed)
 	w.RPC.URL = rpcURL
@@ -43,6 +69,19 @@ func loadChain(t *testing.T, address string) (chain *tokenchain.Chain) {
 	return
 }
 
+func restoreChain(t *testing.T, chain *tokenchain.Chain) (chain2 *tokenchain.Chain) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "chains.db"))
+	require.Nil(t, err)
+	defer db.Close()
+	err = chain.SaveState(db)
+	require.Nil(t, err)
+	chain2, err = tokenchain.LoadChain(chain.Address(), rpcURL)
+	require.Nil(t, err)
+	err = chain2.LoadState(db)
+	require.Nil(t, err)
+	return
+}
+
 var supply = big.NewInt(1e9)
 
 func genesis(t *testing.T, chain *tokenchain.Chain, a *wallet.Account) (token *tokenchain.Token) {
@@ -65,7 +104,15 @@ func assertEqualChain(t *testing.T, c1, c2 *tokenchain.Chain) {
 func assertEqualToken(t *testing.T, t1, t2 *tokenchain.Token) {
 	assert.Equal(t, t1.Name(), t2.Name())
 	assert.Equal(t, t1.Supply(), t2.Supply())
+	assert.Equal(t, t1.Burned(), t2.Burned())
 	assert.Equal(t, t1.Decimals(), t2.Decimals())
+	assert.Equal(t, t1.MintAuthority(), t2.MintAuthority())
+	assert.Equal(t, t1.Issuer(), t2.Issuer())
+	assert.Equal(t, t1.Owner(), t2.Owner())
+	assert.Equal(t, t1.FullName(), t2.FullName())
+	assert.Equal(t, t1.Symbol(), t2.Symbol())
+	assert.Equal(t, t1.Description(), t2.Description())
+	assert.Equal(t, t1.URI(), t2.URI())
 	assert.Equal(t, t1.Hash(), t2.Hash())
 	assert.Equal(t, t1.Balances(), t2.Balances())
 }
@@ -77,6 +124,50 @@ func TestGenesis(t *testing.T) {
 	assertEqualChain(t, chain, loadChain(t, chain.Address()))
 }
 
+func TestGenesisDecimals(t *testing.T) {
+	chain := newChain(t)
+	token, err := tokenchain.TokenGenesis(chain, getAccount(0), "TOKEN", supply, 200)
+	require.Nil(t, err)
+	assert.Equal(t, byte(200), token.Decimals())
+	assert.Empty(t, token.MintAuthority())
+	assert.Empty(t, token.Admin())
+	_, err = tokenchain.MintableTokenGenesis(chain, getAccount(0), "TOKEN", supply, 200, getAccount(1).Address())
+	assert.NotNil(t, err)
+	token2, err := tokenchain.MintableTokenGenesis(chain, getAccount(0), "TOKEN", supply, 18, getAccount(1).Address())
+	require.Nil(t, err)
+	assert.Equal(t, byte(18), token2.Decimals())
+	assert.Equal(t, getAccount(1).Address(), token2.MintAuthority())
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+}
+
+func TestMigrateState(t *testing.T) {
+	chain := newChain(t)
+	token := genesis(t, chain, getAccount(0))
+	_, err := token.Transfer(getAccount(0), getAccount(1).Address(), big.NewInt(1000))
+	require.Nil(t, err)
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "chains.db"))
+	require.Nil(t, err)
+	defer db.Close()
+	require.Nil(t, chain.SaveState(db))
+	for _, stmt := range []string{
+		"CREATE TABLE old_tokens AS SELECT hash, chain, height, name, supply, decimals FROM tokens",
+		"DROP TABLE tokens",
+		"ALTER TABLE old_tokens RENAME TO tokens",
+		"DROP TABLE token_allowances",
+		"DROP TABLE token_metadata",
+		"DROP TABLE swaps",
+	} {
+		_, err = db.Exec(stmt)
+		require.Nil(t, err)
+	}
+	chain2, err := tokenchain.LoadChain(chain.Address(), rpcURL)
+	require.Nil(t, err)
+	require.Nil(t, chain2.LoadState(db))
+	assertEqualChain(t, chain, chain2)
+	require.Nil(t, chain2.Parse())
+	require.Nil(t, chain2.SaveState(db))
+}
+
 func TestTransfer(t *testing.T) {
 	chain := newChain(t)
 	token := genesis(t, chain, getAccount(0))
@@ -87,3 +178,99 @@ func TestTransfer(t *testing.T) {
 	assert.Equal(t, amount, token.Balance(getAccount(1).Address()))
 	assertEqualChain(t, chain, loadChain(t, chain.Address()))
 }
+
+func TestMint(t *testing.T) {
+	chain := newChain(t)
+	token, err := tokenchain.MintableTokenGenesis(chain, getAccount(0), "TOKEN", supply, 5, getAccount(1).Address())
+	require.Nil(t, err)
+	assert.Equal(t, getAccount(1).Address(), token.MintAuthority())
+	amount := big.NewInt(1000)
+	_, err = token.Mint(getAccount(1), getAccount(1).Address(), amount)
+	require.Nil(t, err)
+	assert.Equal(t, new(big.Int).Add(supply, amount), token.Supply())
+	assert.Equal(t, supply, token.Balance(getAccount(0).Address()))
+	assert.Equal(t, amount, token.Balance(getAccount(1).Address()))
+	_, err = token.Mint(getAccount(0), getAccount(1).Address(), amount)
+	require.Nil(t, err)
+	assert.Equal(t, new(big.Int).Add(supply, big.NewInt(2000)), token.Supply())
+	assert.Equal(t, big.NewInt(2000), token.Balance(getAccount(1).Address()))
+	_, err = token.TransferOwnership(getAccount(0), getAccount(1).Address())
+	require.Nil(t, err)
+	_, err = token.Mint(getAccount(0), getAccount(1).Address(), amount)
+	assert.NotNil(t, err)
+	token2 := genesis(t, chain, getAccount(0))
+	assert.Empty(t, token2.MintAuthority())
+	_, err = token2.Mint(getAccount(0), getAccount(1).Address(), amount)
+	assert.NotNil(t, err)
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
+
+func TestBurn(t *testing.T) {
+	chain := newChain(t)
+	token := genesis(t, chain, getAccount(0))
+	amount := big.NewInt(1000)
+	_, err := token.Burn(getAccount(1), amount)
+	assert.NotNil(t, err)
+	_, err = token.Burn(getAccount(0), amount)
+	require.Nil(t, err)
+	assert.Equal(t, new(big.Int).Sub(supply, amount), token.Supply())
+	assert.Equal(t, amount, token.Burned())
+	assert.Equal(t, new(big.Int).Sub(supply, amount), token.Balance(getAccount(0).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
+
+func TestMetadata(t *testing.T) {
+	chain := newChain(t)
+	token := genesis(t, chain, getAccount(0))
+	assert.Equal(t, getAccount(0).Address(), token.Issuer())
+	assert.Equal(t, token.Name(), token.FullName())
+	md := tokenchain.Metadata{
+		Name:        "Community Reward Point",
+		Symbol:      "CRP",
+		Description: "Points awarded to members of the community for their contributions.",
+		URI:         "https://example.com/crp.json",
+	}
+	_, err := token.SetMetadata(getAccount(1), md)
+	assert.NotNil(t, err)
+	_, err = token.SetMetadata(getAccount(0), md)
+	require.Nil(t, err)
+	assert.Equal(t, md.Name, token.FullName())
+	assert.Equal(t, md.Symbol, token.Symbol())
+	assert.Equal(t, md.Description, token.Description())
+	assert.Equal(t, md.URI, token.URI())
+	_, err = token.SetMetadata(getAccount(0), md)
+	assert.NotNil(t, err)
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
+
+func TestUpdateMetadata(t *testing.T) {
+	chain := newChain(t)
+	token := genesis(t, chain, getAccount(0))
+	md := tokenchain.Metadata{Name: "Community Reward Point", Symbol: "CRP"}
+	_, err := token.UpdateMetadata(getAccount(1), "POINTS", md)
+	assert.NotNil(t, err)
+	hash1, err := token.UpdateMetadata(getAccount(0), "POINTS", md)
+	require.Nil(t, err)
+	assert.Equal(t, "POINTS", token.Name())
+	assert.Equal(t, md.Name, token.FullName())
+	md2 := tokenchain.Metadata{Name: "Community Points", Symbol: "CPT", URI: "https://example.com/cpt.json"}
+	hash2, err := token.UpdateMetadata(getAccount(0), "", md2)
+	require.Nil(t, err)
+	assert.Equal(t, "POINTS", token.Name())
+	assert.Equal(t, md2.Symbol, token.Symbol())
+	assert.Equal(t, md2.URI, token.URI())
+	history := []tokenchain.MetadataRecord{
+		{Hash: hash1, Name: "TOKEN"},
+		{Hash: hash2, Name: "POINTS", Metadata: md},
+	}
+	assert.Equal(t, history, token.MetadataHistory())
+	for _, c := range []*tokenchain.Chain{loadChain(t, chain.Address()), restoreChain(t, chain)} {
+		assertEqualChain(t, chain, c)
+		token2, err := c.Token(token.Hash())
+		require.Nil(t, err)
+		assert.Equal(t, history, token2.MetadataHistory())
+	}
+}
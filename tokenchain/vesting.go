@@ -0,0 +1,168 @@
+package tokenchain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/big"
+
+	"github.com/hectorchu/gonano/rpc"
+	"github.com/hectorchu/gonano/wallet"
+)
+
+// Vesting represents a vesting schedule. The tokens are locked until the
+// cliff, after which they release linearly from the start to the end.
+// Points are chain heights. A cliff-only schedule has the same start,
+// cliff and end.
+type Vesting struct {
+	Start, Cliff, End uint32
+}
+
+type vestingGrant struct {
+	amount  *big.Int
+	vesting Vesting
+}
+
+func (v Vesting) check() (err error) {
+	if v.End == 0 || v.Start > v.Cliff || v.Cliff > v.End {
+		err = errors.New("Invalid vesting schedule")
+	}
+	return
+}
+
+// locked returns the amount of a grant still locked at a height, rounded
+// up so that no more is released than due.
+func (g *vestingGrant) locked(p uint32) *big.Int {
+	switch {
+	case p < g.vesting.Cliff:
+		return new(big.Int).Set(g.amount)
+	case p >= g.vesting.End:
+		return new(big.Int)
+	}
+	locked := new(big.Int).Mul(g.amount, big.NewInt(int64(g.vesting.End-p)))
+	span := big.NewInt(int64(g.vesting.End - g.vesting.Start))
+	locked.Add(locked, span).Sub(locked, big.NewInt(1))
+	return locked.Quo(locked, span)
+}
+
+// LockedBalance gets the amount of the balance for account that is still
+// locked by vesting schedules as of the next chain block.
+func (t *Token) LockedBalance(account string) (locked *big.Int) {
+	locked = new(big.Int)
+	height := t.c.now()
+	for _, g := range t.vestings[account] {
+		locked.Add(locked, g.locked(height))
+	}
+	return
+}
+
+// SpendableBalance gets the amount of the balance for account that can be moved.
+func (t *Token) SpendableBalance(account string) (balance *big.Int) {
+	balance = t.Balance(account)
+	if balance.Sub(balance, t.LockedBalance(account)).Sign() < 0 {
+		balance.SetInt64(0)
+	}
+	return
+}
+
+// TransferVesting transfers an amount of tokens to another account,
+// locked under a vesting schedule.
+func (t *Token) TransferVesting(a *wallet.Account, account string, amount *big.Int, v Vesting) (hash rpc.BlockHash, err error) {
+	if err = t.c.Parse(); err != nil {
+		return
+	}
+	if err = t.checkBalance(a.Address(), amount); err != nil {
+		return
+	}
+	if err = t.checkRestrictions(a.Address(), account); err != nil {
+		return
+	}
+	if err = v.check(); err != nil {
+		return
+	}
+	height, err := t.c.getHeight(t.hash)
+	if err != nil {
+		return
+	}
+	return t.c.send(a, []string{account}, &vestingTransferMessage{
+		token:   height,
+		amount:  amount,
+		vesting: v,
+	})
+}
+
+func (m *vestingTransferMessage) process(ctx context.Context, c *Chain, hash rpc.BlockHash, height uint32, info rpc.BlockInfo) (valid bool, err error) {
+	t, ok := c.tokens[m.token]
+	if !ok {
+		return
+	}
+	if t.checkBalance(info.BlockAccount, m.amount) != nil {
+		return
+	}
+	destination, valid, err := c.getDestination(ctx, info.Contents)
+	if !valid {
+		return
+	}
+	data, valid, err := c.getExtension(ctx, info.Contents, 1)
+	if !valid {
+		return
+	}
+	if m.setExtension(data) != nil || m.vesting.check() != nil || t.checkRestrictions(info.BlockAccount, destination) != nil {
+		return false, nil
+	}
+	t.transfer(hash, info.BlockAccount, destination, m.amount, "")
+	t.vestings[destination] = append(t.vestings[destination], &vestingGrant{
+		amount:  m.amount,
+		vesting: m.vesting,
+	})
+	return
+}
+
+func (t *Token) loadVestings(db *sql.DB, hash string) (err error) {
+	t.vestings = make(map[string][]*vestingGrant)
+	rows, err := db.Query(`
+		SELECT account, amount, start_point, cliff_point, end_point
+		FROM token_vestings WHERE hash = ? ORDER BY account, seq
+	`, hash)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			account, amountStr string
+			g                  vestingGrant
+			ok                 bool
+		)
+		if err = rows.Scan(&account, &amountStr, &g.vesting.Start, &g.vesting.Cliff, &g.vesting.End); err != nil {
+			return
+		}
+		if g.amount, ok = new(big.Int).SetString(amountStr, 10); !ok {
+			return errors.New("Failed to parse amount from DB")
+		}
+		t.vestings[account] = append(t.vestings[account], &g)
+	}
+	return rows.Err()
+}
+
+func (t *Token) saveVestings(tx *sql.Tx, hash string) (err error) {
+	stmt, err := tx.Prepare(`
+		REPLACE INTO token_vestings (hash, account, seq, amount, start_point, cliff_point, end_point)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	for account, grants := range t.vestings {
+		for i, g := range grants {
+			if _, err = stmt.Exec(
+				hash, account, i, g.amount.String(),
+				g.vesting.Start, g.vesting.Cliff, g.vesting.End,
+			); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
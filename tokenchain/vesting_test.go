@@ -0,0 +1,74 @@
+package tokenchain_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVesting(t *testing.T) {
+	var (
+		chain  = newChain(t)
+		token  = genesis(t, chain, getAccount(0))
+		amount = big.NewInt(1000)
+	)
+	height, err := chain.BlockHeight(token.Hash())
+	require.Nil(t, err)
+	_, err = token.TransferVesting(getAccount(0), getAccount(1).Address(), amount, tokenchain.Vesting{Start: 2, Cliff: 1, End: 3})
+	assert.NotNil(t, err)
+	cliff := height + 3
+	hash, err := token.TransferVesting(getAccount(0), getAccount(1).Address(), amount, tokenchain.Vesting{
+		Start: cliff,
+		Cliff: cliff,
+		End:   cliff,
+	})
+	require.Nil(t, err)
+	height, err = chain.BlockHeight(hash)
+	require.Nil(t, err)
+	require.Less(t, height+1, cliff)
+	assert.Equal(t, amount, token.Balance(getAccount(1).Address()))
+	assert.Equal(t, amount, token.LockedBalance(getAccount(1).Address()))
+	assert.Zero(t, token.SpendableBalance(getAccount(1).Address()).Sign())
+	_, err = token.Transfer(getAccount(1), getAccount(0).Address(), big.NewInt(1))
+	assert.NotNil(t, err)
+	chain2 := restoreChain(t, chain)
+	token2, err := chain2.Token(token.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, amount, token2.LockedBalance(getAccount(1).Address()))
+	_, err = token.Transfer(getAccount(0), getAccount(1).Address(), amount)
+	require.Nil(t, err)
+	assert.Zero(t, token.LockedBalance(getAccount(1).Address()).Sign())
+	_, err = token.Transfer(getAccount(1), getAccount(0).Address(), new(big.Int).Mul(amount, big.NewInt(2)))
+	require.Nil(t, err)
+	assert.Equal(t, supply, token.Balance(getAccount(0).Address()))
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+}
+
+func TestVestingLinear(t *testing.T) {
+	var (
+		chain  = newChain(t)
+		token  = genesis(t, chain, getAccount(0))
+		amount = big.NewInt(1000)
+	)
+	height, err := chain.BlockHeight(token.Hash())
+	require.Nil(t, err)
+	_, err = token.TransferVesting(getAccount(0), getAccount(1).Address(), amount, tokenchain.Vesting{
+		Start: height,
+		Cliff: height,
+		End:   height + 100,
+	})
+	require.Nil(t, err)
+	locked := token.LockedBalance(getAccount(1).Address())
+	assert.Equal(t, big.NewInt(980), locked)
+	spendable := token.SpendableBalance(getAccount(1).Address())
+	assert.Equal(t, amount, spendable.Add(spendable, locked))
+	_, err = token.Transfer(getAccount(1), getAccount(0).Address(), amount)
+	assert.NotNil(t, err)
+	_, err = token.Transfer(getAccount(1), getAccount(0).Address(), big.NewInt(1))
+	require.Nil(t, err)
+	assertEqualChain(t, chain, loadChain(t, chain.Address()))
+	assertEqualChain(t, chain, restoreChain(t, chain))
+}
@@ -20,31 +20,33 @@ import (
 
 type chainManager struct {
 	m           sync.Mutex
+	node        tokenchain.NodeBackend
 	chains      map[string]*tokenchain.Chain
 	lastUpdated time.Time
 }
 
-func newChainManager(rpcURL, wsURL string) (cm *chainManager, err error) {
+func newChainManager(node tokenchain.NodeBackend, wsURL string) (cm *chainManager, err error) {
 	cm = &chainManager{
+		node:        node,
 		chains:      make(map[string]*tokenchain.Chain),
 		lastUpdated: time.Date(2020, 12, 25, 0, 0, 0, 0, time.UTC),
 	}
 	if _, err := os.Stat("./chains.db"); err == nil {
-		if err = withDB(func(db *sql.DB) error { return cm.loadState(db, rpcURL) }); err != nil {
+		if err = withDB(func(db *sql.DB) error { return cm.loadState(db) }); err != nil {
 			return nil, err
 		}
 	}
-	return cm, cm.connect(rpcURL, wsURL)
+	return cm, cm.connect(wsURL)
 }
 
-func (cm *chainManager) connect(rpcURL, wsURL string) (err error) {
+func (cm *chainManager) connect(wsURL string) (err error) {
 	log.Println("Catching up...")
 	for {
 		lastUpdated := time.Now().UTC()
 		if lastUpdated.Sub(cm.lastUpdated) < 5*time.Minute {
 			break
 		}
-		if err = cm.scanForChains(rpcURL); err != nil {
+		if err = cm.scanForChains(); err != nil {
 			return
 		}
 		cm.lastUpdated = lastUpdated
@@ -67,13 +69,13 @@ func (cm *chainManager) connect(rpcURL, wsURL string) (err error) {
 			messages <- m
 		}
 	}()
-	if err = cm.scanForChains(rpcURL); err != nil {
+	if err = cm.scanForChains(); err != nil {
 		ws.Close()
 		<-messages
 		return
 	}
 	log.Println("...done")
-	go cm.loop(ws, messages, rpcURL, wsURL)
+	go cm.loop(ws, messages, wsURL)
 	return
 }
 
@@ -83,11 +85,11 @@ func (cm *chainManager) withLock(cb func()) {
 	cm.m.Unlock()
 }
 
-func (cm *chainManager) loop(ws *websocket.Client, messages <-chan interface{}, rpcURL, wsURL string) {
+func (cm *chainManager) loop(ws *websocket.Client, messages <-chan interface{}, wsURL string) {
 	for {
 		switch m := (<-messages).(type) {
 		case *websocket.Confirmation:
-			if err := cm.scanForChain(m.Block, rpcURL); err != nil {
+			if err := cm.scanForChain(m.Block); err != nil {
 				log.Fatalln(err)
 			}
 			cm.lastUpdated = m.Time
@@ -96,7 +98,7 @@ func (cm *chainManager) loop(ws *websocket.Client, messages <-chan interface{},
 			ws.Close()
 			<-messages
 			for {
-				err := cm.connect(rpcURL, wsURL)
+				err := cm.connect(wsURL)
 				if err == nil {
 					return
 				}
@@ -110,15 +112,14 @@ func (cm *chainManager) loop(ws *websocket.Client, messages <-chan interface{},
 	}
 }
 
-func (cm *chainManager) scanForChains(rpcURL string) (err error) {
-	client := rpc.Client{URL: rpcURL}
+func (cm *chainManager) scanForChains() (err error) {
 	account, err := util.PubkeyToAddress(make([]byte, 32))
 	if err != nil {
 		return
 	}
 	for {
 		const batchSize = 1e4
-		accounts, err := client.Ledger(account, batchSize, cm.lastUpdated)
+		accounts, err := cm.node.Ledger(account, batchSize, cm.lastUpdated)
 		if err != nil {
 			return err
 		}
@@ -136,7 +137,7 @@ func (cm *chainManager) scanForChains(rpcURL string) (err error) {
 		sort.Slice(addresses, func(i, j int) bool {
 			return strings.Compare(addresses[i], addresses[j]) < 0
 		})
-		blocks, err := client.Blocks(hashes)
+		blocks, err := cm.node.Blocks(hashes)
 		if err != nil {
 			return err
 		}
@@ -144,7 +145,7 @@ func (cm *chainManager) scanForChains(rpcURL string) (err error) {
 			if address == account {
 				continue
 			}
-			if err = cm.scanForChain(blocks[info.OpenBlock.String()], rpcURL); err != nil {
+			if err = cm.scanForChain(blocks[info.OpenBlock.String()]); err != nil {
 				return err
 			}
 		}
@@ -156,7 +157,7 @@ func (cm *chainManager) scanForChains(rpcURL string) (err error) {
 	return
 }
 
-func (cm *chainManager) scanForChain(block *rpc.Block, rpcURL string) (err error) {
+func (cm *chainManager) scanForChain(block *rpc.Block) (err error) {
 	c, ok := cm.chains[block.Account]
 	if !ok {
 		if bytes.Count(block.Previous, []byte{0}) != len(block.Previous) {
@@ -166,7 +167,7 @@ func (cm *chainManager) scanForChain(block *rpc.Block, rpcURL string) (err error
 		if err != nil {
 			return err
 		}
-		if c, err = tokenchain.NewChainFromSeed(seed, rpcURL); err != nil {
+		if c, err = tokenchain.NewChainFromSeedWithBackend(seed, cm.node); err != nil {
 			return err
 		}
 		if c.Address() != block.Account {
@@ -194,7 +195,7 @@ func withDB(cb func(*sql.DB) error) (err error) {
 	return cb(db)
 }
 
-func (cm *chainManager) loadState(db *sql.DB, rpcURL string) (err error) {
+func (cm *chainManager) loadState(db *sql.DB) (err error) {
 	rows, err := db.Query("SELECT seed FROM chains")
 	if err != nil {
 		return
@@ -209,7 +210,7 @@ func (cm *chainManager) loadState(db *sql.DB, rpcURL string) (err error) {
 		if err != nil {
 			return err
 		}
-		c, err := tokenchain.NewChainFromSeed(seed, rpcURL)
+		c, err := tokenchain.NewChainFromSeedWithBackend(seed, cm.node)
 		if err != nil {
 			return err
 		}
@@ -3,6 +3,8 @@ package main
 import (
 	"log"
 	"net/http"
+
+	"github.com/hectorchu/gonano/rpc"
 )
 
 func main() {
@@ -10,7 +12,7 @@ func main() {
 		rpcURL = "http://[::1]:7076"
 		wsURL  = "ws://[::1]:7078"
 	)
-	cm, err := newChainManager(rpcURL, wsURL)
+	cm, err := newChainManager(&rpc.Client{URL: rpcURL}, wsURL)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -5,9 +5,13 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"io"
+	"math/big"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/hectorchu/nano-token-protocol/tokenchain"
 )
 
 func rpcHandler(cm *chainManager) http.HandlerFunc {
@@ -31,6 +35,34 @@ func rpcHandler(cm *chainManager) http.HandlerFunc {
 			result = getTokenBalances(cm, &buf)
 		case "token_balance":
 			result = getTokenBalance(cm, &buf)
+		case "token_allowance":
+			result = getTokenAllowance(cm, &buf)
+		case "token_transfers":
+			result = getTokenTransfers(cm, &buf)
+		case "token_metadata_history":
+			result = getTokenMetadataHistory(cm, &buf)
+		case "swap":
+			result = getSwap(cm, &buf)
+		case "order_book":
+			result = getOrderBook(cm)
+		case "htlc":
+			result = getHTLC(cm, &buf)
+		case "htlcs":
+			result = getHTLCs(cm, &buf)
+		case "collections":
+			result = getCollections(cm)
+		case "collection":
+			result = getCollection(cm, &buf)
+		case "collection_items":
+			result = getCollectionItems(cm, &buf)
+		case "collection_item":
+			result = getCollectionItem(cm, &buf)
+		case "multi_tokens":
+			result = getMultiTokens(cm)
+		case "multi_token":
+			result = getMultiToken(cm, &buf)
+		case "multi_token_balances":
+			result = getMultiTokenBalances(cm, &buf)
 		}
 		json.NewEncoder(w).Encode(result)
 	}
@@ -42,10 +74,20 @@ func getTokens(cm *chainManager) (result map[string]interface{}) {
 		for _, c := range cm.chains {
 			for _, t := range c.Tokens() {
 				hash := strings.ToUpper(hex.EncodeToString(t.Hash()))
-				result[hash] = struct{ Name, Supply, Decimals string }{
-					Name:     t.Name(),
-					Supply:   t.Supply().String(),
-					Decimals: strconv.Itoa(int(t.Decimals())),
+				result[hash] = struct {
+					Name, Supply, Decimals, MintAuthority string
+					FullName, Symbol, Description, URI    string
+					Owner                                 string
+				}{
+					Name:          t.Name(),
+					Supply:        t.Supply().String(),
+					Decimals:      strconv.Itoa(int(t.Decimals())),
+					MintAuthority: t.MintAuthority(),
+					FullName:      t.FullName(),
+					Symbol:        t.Symbol(),
+					Description:   t.Description(),
+					URI:           t.URI(),
+					Owner:         t.Owner(),
 				}
 			}
 		}
@@ -70,7 +112,17 @@ func getToken(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{
 			if t, err := c.Token(hash); err == nil {
 				result["Name"] = t.Name()
 				result["Supply"] = t.Supply().String()
+				result["Burned"] = t.Burned().String()
 				result["Decimals"] = strconv.Itoa(int(t.Decimals()))
+				result["MintAuthority"] = t.MintAuthority()
+				result["FullName"] = t.FullName()
+				result["Symbol"] = t.Symbol()
+				result["Description"] = t.Description()
+				result["URI"] = t.URI()
+				result["Owner"] = t.Owner()
+				result["PendingOwner"] = t.PendingOwner()
+				result["Admin"] = t.Admin()
+				result["Paused"] = t.Paused()
 				return
 			}
 		}
@@ -121,6 +173,67 @@ func getTokenBalance(cm *chainManager, buf *bytes.Buffer) (result map[string]int
 		for _, c := range cm.chains {
 			if t, err := c.Token(hash); err == nil {
 				result["Balance"] = t.Balance(v.Account).String()
+				result["Spendable"] = t.SpendableBalance(v.Account).String()
+				result["Locked"] = t.LockedBalance(v.Account).String()
+				result["Frozen"] = t.Frozen(v.Account)
+				return
+			}
+		}
+		result["error"] = "Token not found"
+	})
+	return
+}
+
+func getTokenAllowance(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hash, Owner, Spender string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		result["error"] = "Unable to decode hash"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			if t, err := c.Token(hash); err == nil {
+				result["Allowance"] = t.Allowance(v.Owner, v.Spender).String()
+				return
+			}
+		}
+		result["error"] = "Token not found"
+	})
+	return
+}
+
+func getTokenTransfers(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hash, Account string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		result["error"] = "Unable to decode hash"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			if t, err := c.Token(hash); err == nil {
+				transfers := []map[string]string{}
+				for _, tr := range t.Transfers(v.Account) {
+					transfers = append(transfers, map[string]string{
+						"Hash":   strings.ToUpper(hex.EncodeToString(tr.Hash)),
+						"From":   tr.From,
+						"To":     tr.To,
+						"Amount": tr.Amount.String(),
+						"Memo":   tr.Memo,
+					})
+				}
+				result["Transfers"] = transfers
 				return
 			}
 		}
@@ -128,3 +241,368 @@ func getTokenBalance(cm *chainManager, buf *bytes.Buffer) (result map[string]int
 	})
 	return
 }
+
+func getTokenMetadataHistory(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hash string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		result["error"] = "Unable to decode hash"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			if t, err := c.Token(hash); err == nil {
+				history := []map[string]string{}
+				for _, r := range t.MetadataHistory() {
+					history = append(history, map[string]string{
+						"Hash":        strings.ToUpper(hex.EncodeToString(r.Hash)),
+						"Name":        r.Name,
+						"FullName":    r.Metadata.Name,
+						"Symbol":      r.Metadata.Symbol,
+						"Description": r.Metadata.Description,
+						"URI":         r.Metadata.URI,
+					})
+				}
+				result["History"] = history
+				return
+			}
+		}
+		result["error"] = "Token not found"
+	})
+	return
+}
+
+func getSwap(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hash string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		result["error"] = "Unable to decode hash"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			if s, err := c.Swap(hash); err == nil {
+				result["Left"] = swapLeg(s.Left())
+				result["Right"] = swapLeg(s.Right())
+				result["Offer"] = s.Offer()
+				result["Nano"] = s.Nano()
+				result["Remaining"] = s.Remaining().String()
+				fills := []map[string]string{}
+				for _, f := range s.Fills() {
+					fills = append(fills, map[string]string{
+						"Hash":    strings.ToUpper(hex.EncodeToString(f.Hash)),
+						"Account": f.Account,
+						"Amount":  f.Amount.String(),
+						"Paid":    f.Paid.String(),
+					})
+				}
+				result["Fills"] = fills
+				result["ExpiryHeight"] = strconv.FormatUint(uint64(s.Expiry().Height), 10)
+				return
+			}
+		}
+		result["error"] = "Swap not found"
+	})
+	return
+}
+
+func getOrderBook(cm *chainManager) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	cm.withLock(func() {
+		books := make(map[string][]*tokenchain.Swap)
+		for _, c := range cm.chains {
+			for _, s := range c.Offers() {
+				pair := strings.ToUpper(hex.EncodeToString(s.Left().Token.Hash())) + "_" +
+					strings.ToUpper(hex.EncodeToString(s.Right().Token.Hash()))
+				books[pair] = append(books[pair], s)
+			}
+		}
+		for pair, offers := range books {
+			sort.Slice(offers, func(i, j int) bool {
+				x := new(big.Int).Mul(offers[i].Right().Amount, offers[j].Left().Amount)
+				y := new(big.Int).Mul(offers[j].Right().Amount, offers[i].Left().Amount)
+				return x.Cmp(y) < 0
+			})
+			book := make([]interface{}, len(offers))
+			for i, s := range offers {
+				book[i] = struct{ Hash, Account, Amount, MinAmount, Remaining string }{
+					Hash:      strings.ToUpper(hex.EncodeToString(s.Hash())),
+					Account:   s.Left().Account,
+					Amount:    s.Left().Amount.String(),
+					MinAmount: s.Right().Amount.String(),
+					Remaining: s.Remaining().String(),
+				}
+			}
+			result[pair] = book
+		}
+	})
+	return
+}
+
+func getHTLC(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hash string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		result["error"] = "Unable to decode hash"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			if h, err := c.HTLC(hash); err == nil {
+				result = htlcInfo(h)
+				return
+			}
+		}
+		result["error"] = "HTLC not found"
+	})
+	return
+}
+
+func getHTLCs(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hashlock string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hashlock, err := hex.DecodeString(v.Hashlock)
+	if err != nil {
+		result["error"] = "Unable to decode hashlock"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			for _, h := range c.HTLCs() {
+				if len(hashlock) == 0 || bytes.Equal(hashlock, h.Hashlock()) {
+					result[strings.ToUpper(hex.EncodeToString(h.Hash()))] = htlcInfo(h)
+				}
+			}
+		}
+	})
+	return
+}
+
+func htlcInfo(h *tokenchain.HTLC) (info map[string]interface{}) {
+	info = map[string]interface{}{
+		"Token":         strings.ToUpper(hex.EncodeToString(h.Token().Hash())),
+		"Sender":        h.Sender(),
+		"Recipient":     h.Recipient(),
+		"Amount":        h.Amount().String(),
+		"Hashlock":      strings.ToUpper(hex.EncodeToString(h.Hashlock())),
+		"TimeoutHeight": strconv.FormatUint(uint64(h.Timeout()), 10),
+		"Active":        h.Active(),
+		"Expired":       h.Expired(),
+	}
+	if preimage := h.Preimage(); preimage != nil {
+		info["Preimage"] = strings.ToUpper(hex.EncodeToString(preimage))
+	}
+	return
+}
+
+func getCollections(cm *chainManager) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			for _, col := range c.Collections() {
+				hash := strings.ToUpper(hex.EncodeToString(col.Hash()))
+				result[hash] = struct{ Name, Issuer string }{
+					Name:   col.Name(),
+					Issuer: col.Issuer(),
+				}
+			}
+		}
+	})
+	return
+}
+
+func getCollection(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hash string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		result["error"] = "Unable to decode hash"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			if col, err := c.Collection(hash); err == nil {
+				result["Name"] = col.Name()
+				result["Issuer"] = col.Issuer()
+				result["Items"] = itemIDs(col.Items())
+				return
+			}
+		}
+		result["error"] = "Collection not found"
+	})
+	return
+}
+
+func getCollectionItems(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hash, Account string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		result["error"] = "Unable to decode hash"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			if col, err := c.Collection(hash); err == nil {
+				result["Items"] = itemIDs(col.ItemsOf(v.Account))
+				return
+			}
+		}
+		result["error"] = "Collection not found"
+	})
+	return
+}
+
+func getCollectionItem(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hash, ID string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		result["error"] = "Unable to decode hash"
+		return
+	}
+	id, err := strconv.ParseUint(v.ID, 10, 64)
+	if err != nil {
+		result["error"] = "Unable to decode ID"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			if col, err := c.Collection(hash); err == nil {
+				owner, err := col.Owner(id)
+				if err != nil {
+					result["error"] = err.Error()
+					return
+				}
+				contentHash, _ := col.ContentHash(id)
+				result["Owner"] = owner
+				result["ContentHash"] = strings.ToUpper(hex.EncodeToString(contentHash))
+				return
+			}
+		}
+		result["error"] = "Collection not found"
+	})
+	return
+}
+
+func getMultiTokens(cm *chainManager) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			for _, mt := range c.MultiTokens() {
+				hash := strings.ToUpper(hex.EncodeToString(mt.Hash()))
+				result[hash] = struct{ Name, Issuer string }{
+					Name:   mt.Name(),
+					Issuer: mt.Issuer(),
+				}
+			}
+		}
+	})
+	return
+}
+
+func getMultiToken(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hash string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		result["error"] = "Unable to decode hash"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			if mt, err := c.MultiToken(hash); err == nil {
+				supplies := make(map[string]string)
+				for _, id := range mt.Classes() {
+					supplies[strconv.FormatUint(uint64(id), 10)] = mt.Supply(id).String()
+				}
+				result["Name"] = mt.Name()
+				result["Issuer"] = mt.Issuer()
+				result["Supplies"] = supplies
+				return
+			}
+		}
+		result["error"] = "Multi-token not found"
+	})
+	return
+}
+
+func getMultiTokenBalances(cm *chainManager, buf *bytes.Buffer) (result map[string]interface{}) {
+	result = make(map[string]interface{})
+	var v struct{ Hash, Account string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		result["error"] = "Unable to decode request"
+		return
+	}
+	hash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		result["error"] = "Unable to decode hash"
+		return
+	}
+	cm.withLock(func() {
+		for _, c := range cm.chains {
+			if mt, err := c.MultiToken(hash); err == nil {
+				for id, balance := range mt.Balances(v.Account) {
+					result[strconv.FormatUint(uint64(id), 10)] = balance.String()
+				}
+				return
+			}
+		}
+		result["error"] = "Multi-token not found"
+	})
+	return
+}
+
+func itemIDs(ids []uint64) (s []string) {
+	s = []string{}
+	for _, id := range ids {
+		s = append(s, strconv.FormatUint(id, 10))
+	}
+	return
+}
+
+func swapLeg(sl tokenchain.SwapLeg) (leg map[string]string) {
+	leg = map[string]string{"Account": sl.Account}
+	if sl.Token != nil {
+		leg["Token"] = strings.ToUpper(hex.EncodeToString(sl.Token.Hash()))
+	}
+	if sl.Amount != nil {
+		leg["Amount"] = sl.Amount.String()
+	}
+	return
+}